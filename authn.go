@@ -21,14 +21,51 @@ import (
 	"net/http"
 )
 
+// SetSessionSource replaces kratos as the source of the sessions resolved by AuthRequestFunc
+// and AuthGRPCFunc, kratos is used again when source is nil
+func SetSessionSource(source SessionSource) {
+	Source = source
+}
+
+// sessionSource returns the configured source, kratos by default
+func sessionSource() SessionSource {
+	if Source != nil {
+		return Source
+	}
+	return Kratox
+}
+
+// AuthRequestFunc resolves the session from the http request and records the cookie
+// and the session into the context.
+// The session cookie is mandatory unless an other session source is set
 func AuthRequestFunc(ctx context.Context, r *http.Request) context.Context {
 	ctx2, err := SetCookieFromHttpToCtx(ctx, r)
-	if err != nil {
+	if err != nil && Source == nil {
 		logx.WithName(ctx, "OptionAuthn").Info("get kratos cookie from http request failed")
 		return ctx
 	}
-	ctx = ctx2
-	session, err := Kratox.GetSessionFromHTTP(ctx, r)
+	if err == nil {
+		ctx = ctx2
+	}
+	session, err := sessionSource().GetSessionFromHTTP(ctx, r)
+	if err != nil {
+		logx.WithName(ctx, "OptionAuthn").Info("get session from kratos failed")
+		return ctx
+	}
+	if session == nil {
+		logx.WithName(ctx, "OptionAuthn").Info("get session from kratos failed")
+		return ctx
+	}
+	return SetSessionInCtx(ctx, session)
+}
+
+// AuthGRPCFunc resolves the session from the incoming gRPC metadata and records
+// the session credential and the session into the context
+func AuthGRPCFunc(ctx context.Context) context.Context {
+	if cred, ok := extractor().FromGRPC(ctx); ok {
+		ctx = SetCredentialInCtx(ctx, cred)
+	}
+	session, err := sessionSource().GetSessionFromGRPCCtx(ctx)
 	if err != nil {
 		logx.WithName(ctx, "OptionAuthn").Info("get session from kratos failed")
 		return ctx
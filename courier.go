@@ -0,0 +1,176 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	client "github.com/ory/kratos-client-go"
+
+	"github.com/w6d-io/x/errorx"
+	"github.com/w6d-io/x/logx"
+)
+
+const (
+	// courierPollInterval is the pause between two listings of WaitForMessage
+	courierPollInterval = 200 * time.Millisecond
+)
+
+var (
+	// ErrNoCode is returned when the message body holds no code
+	ErrNoCode = errorx.New("no code in the message")
+	// ErrNoLink is returned when the message body holds no link
+	ErrNoLink = errorx.New("no link in the message")
+
+	codeRegexp = regexp.MustCompile(`\b\d{6}\b`)
+	linkRegexp = regexp.MustCompile(`https?://[^\s"'<>]+`)
+)
+
+// MessageFilter selects the courier messages
+type MessageFilter struct {
+	// Recipient is the email address or the phone number
+	Recipient string
+	// Status of the messages, all when empty
+	Status client.CourierMessageStatus
+	// PageSize is the number of messages by page, the kratos default when zero
+	PageSize int64
+	// PageToken is the NextPageToken of the previous page
+	PageToken string
+}
+
+// MessagePage is a page of courier messages
+type MessagePage struct {
+	Messages []client.Message
+	// NextPageToken selects the next page, empty on the last one
+	NextPageToken string
+}
+
+// ListCourierMessages lists the messages sent or to send by the kratos courier, newest first
+// if kratos is unreachable or an other issues, return nil with statusCode of the call and error-go
+func (a auth) ListCourierMessages(ctx context.Context, filter MessageFilter) (*MessagePage, error) {
+	log := logx.WithName(ctx, "ListCourierMessages")
+	api, err := a.adminAPI()
+	if err != nil {
+		return nil, err
+	}
+	req := api.CourierApi.ListCourierMessages(ctx)
+	if filter.Recipient != "" {
+		req = req.Recipient(filter.Recipient)
+	}
+	if filter.Status != "" {
+		req = req.Status(filter.Status)
+	}
+	if filter.PageSize > 0 {
+		req = req.PageSize(filter.PageSize)
+	}
+	if filter.PageToken != "" {
+		req = req.PageToken(filter.PageToken)
+	}
+	messages, r, err := req.Execute()
+	if err != nil {
+		log.Error(err, "calling fail", "name", "ListCourierMessages", "response", r)
+		return nil, errorx.NewHTTP(err, statusOf(r, err), "fail to call kratos")
+	}
+	return &MessagePage{Messages: messages, NextPageToken: nextLinkParam(r.Header, "page_token")}, nil
+}
+
+// GetCourierMessage returns the courier message with the id
+// if kratos is unreachable or an other issues, return nil with statusCode of the call and error-go
+func (a auth) GetCourierMessage(ctx context.Context, id string) (*client.Message, error) {
+	log := logx.WithName(ctx, "GetCourierMessage")
+	api, err := a.adminAPI()
+	if err != nil {
+		return nil, err
+	}
+	m, r, err := api.CourierApi.GetCourierMessage(ctx, id).Execute()
+	if err != nil {
+		log.Error(err, "calling fail", "name", "GetCourierMessage", "response", r)
+		return nil, errorx.NewHTTP(err, statusOf(r, err), "fail to call kratos")
+	}
+	return m, nil
+}
+
+// WaitForMessage waits for a message to the recipient created after the time, and returns the newest.
+// It is meant for tests, set a deadline on the context
+func WaitForMessage(ctx context.Context, recipient string, after time.Time) (*client.Message, error) {
+	ticker := time.NewTicker(courierPollInterval)
+	defer ticker.Stop()
+	for {
+		page, err := Kratox.ListCourierMessages(ctx, MessageFilter{Recipient: recipient})
+		if err != nil {
+			return nil, err
+		}
+		for i := range page.Messages {
+			if page.Messages[i].CreatedAt.After(after) {
+				return &page.Messages[i], nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil, errorx.NewHTTP(ctx.Err(), http.StatusRequestTimeout, "no message for the recipient")
+		case <-ticker.C:
+		}
+	}
+}
+
+// ExtractCode returns the six digits code of the verification, recovery or login message
+func ExtractCode(m *client.Message) (string, error) {
+	code := codeRegexp.FindString(m.Body)
+	if code == "" {
+		return "", ErrNoCode
+	}
+	return code, nil
+}
+
+// ExtractLink returns the self-service link of the message, the first link when there is none
+func ExtractLink(m *client.Message) (string, error) {
+	links := linkRegexp.FindAllString(m.Body, -1)
+	if len(links) == 0 {
+		return "", ErrNoLink
+	}
+	for _, l := range links {
+		if strings.Contains(l, "/self-service/") {
+			return l, nil
+		}
+	}
+	return links[0], nil
+}
+
+// nextLinkParam reads the query parameter of the next link of the kratos pagination header
+func nextLinkParam(header http.Header, name string) string {
+	return nextLinkQuery(header).Get(name)
+}
+
+// nextLinkQuery reads the query of the next link of the kratos pagination header, nil without next link
+func nextLinkQuery(header http.Header) url.Values {
+	for _, link := range strings.Split(header.Get("Link"), ",") {
+		parts := strings.Split(link, ";")
+		if len(parts) < 2 || !strings.Contains(parts[1], `rel="next"`) {
+			continue
+		}
+		u, err := url.Parse(strings.Trim(strings.TrimSpace(parts[0]), "<>"))
+		if err != nil {
+			return nil
+		}
+		return u.Query()
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	client "github.com/ory/kratos-client-go"
+
+	"github.com/w6d-io/kratox"
+)
+
+// fakeCourier serves the courier messages, two by page
+type fakeCourier struct {
+	mu       sync.Mutex
+	messages []map[string]interface{}
+}
+
+func (f *fakeCourier) add(id, recipient, status, body string, createdAt time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.messages = append([]map[string]interface{}{{
+		"id": id, "recipient": recipient, "status": status, "body": body, "subject": "subject",
+		"template_type": "verification_code", "type": "email", "send_count": 1,
+		"created_at": createdAt.UTC().Format(time.RFC3339Nano), "updated_at": createdAt.UTC().Format(time.RFC3339Nano),
+	}}, f.messages...)
+}
+
+func (f *fakeCourier) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Path != "/admin/courier/messages" {
+		for _, m := range f.messages {
+			if r.URL.Path == "/admin/courier/messages/"+m["id"].(string) {
+				_ = json.NewEncoder(w).Encode(m)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]interface{}{"code": 404, "message": "not found"}})
+		return
+	}
+	q := r.URL.Query()
+	var matching []map[string]interface{}
+	for _, m := range f.messages {
+		if (q.Get("recipient") == "" || m["recipient"] == q.Get("recipient")) && (q.Get("status") == "" || m["status"] == q.Get("status")) {
+			matching = append(matching, m)
+		}
+	}
+	start := 0
+	if q.Get("page_token") == "page-2" {
+		start = 2
+	}
+	end := start + 2
+	if end >= len(matching) {
+		end = len(matching)
+	} else {
+		w.Header().Set("Link", `<http://kratos/admin/courier/messages?page_size=2&page_token=page-2>; rel="next",<http://kratos/admin/courier/messages?page_size=2>; rel="first"`)
+	}
+	if start > len(matching) {
+		start = len(matching)
+	}
+	_ = json.NewEncoder(w).Encode(matching[start:end])
+}
+
+func TestCourierMessages(t *testing.T) {
+	fake := &fakeCourier{}
+	now := time.Now()
+	fake.add("1", "a@b.c", "sent", "Your code is 123456", now.Add(-3*time.Minute))
+	fake.add("2", "a@b.c", "sent", "Recover your account http://kratos/self-service/recovery?flow=abc&token=def", now.Add(-2*time.Minute))
+	fake.add("3", "a@b.c", "queued", "Hi", now.Add(-time.Minute))
+	fake.add("4", "d@e.f", "sent", "Hello", now.Add(-time.Minute))
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	kratox.SetAddress(srv.URL, srv.URL)
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		filter    kratox.MessageFilter
+		wantIDs   []string
+		wantToken string
+	}{
+		{name: "by recipient", filter: kratox.MessageFilter{Recipient: "a@b.c", PageSize: 2}, wantIDs: []string{"3", "2"}, wantToken: "page-2"},
+		{name: "next page", filter: kratox.MessageFilter{Recipient: "a@b.c", PageSize: 2, PageToken: "page-2"}, wantIDs: []string{"1"}},
+		{name: "by status", filter: kratox.MessageFilter{Status: client.COURIERMESSAGESTATUS_QUEUED}, wantIDs: []string{"3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := kratox.Kratox.ListCourierMessages(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("ListCourierMessages() error = %v", err)
+			}
+			var ids []string
+			for _, m := range got.Messages {
+				ids = append(ids, m.Id)
+			}
+			if len(ids) != len(tt.wantIDs) || got.NextPageToken != tt.wantToken {
+				t.Fatalf("ListCourierMessages() = %v, %s", ids, got.NextPageToken)
+			}
+			for i := range ids {
+				if ids[i] != tt.wantIDs[i] {
+					t.Errorf("ListCourierMessages() = %v, want %v", ids, tt.wantIDs)
+				}
+			}
+		})
+	}
+
+	m, err := kratox.Kratox.GetCourierMessage(ctx, "1")
+	if err != nil || m.Recipient != "a@b.c" {
+		t.Fatalf("GetCourierMessage() = %v, %v", m, err)
+	}
+	if code, err := kratox.ExtractCode(m); err != nil || code != "123456" {
+		t.Errorf("ExtractCode() = %s, %v", code, err)
+	}
+	if _, err := kratox.ExtractLink(m); !errors.Is(err, kratox.ErrNoLink) {
+		t.Errorf("ExtractLink() error = %v, want %v", err, kratox.ErrNoLink)
+	}
+	if _, err := kratox.Kratox.GetCourierMessage(ctx, "unknown"); err == nil {
+		t.Error("GetCourierMessage() expected an error for an unknown message")
+	}
+
+	t.Run("wait for message", func(t *testing.T) {
+		since := time.Now()
+		go func() {
+			time.Sleep(300 * time.Millisecond)
+			fake.add("5", "g@h.i", "sent", "Verify your address: http://kratos/self-service/verification?flow=xyz&code=654321", time.Now())
+		}()
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		m, err := kratox.WaitForMessage(ctx, "g@h.i", since)
+		if err != nil || m.Id != "5" {
+			t.Fatalf("WaitForMessage() = %v, %v", m, err)
+		}
+		if link, err := kratox.ExtractLink(m); err != nil || link != "http://kratos/self-service/verification?flow=xyz&code=654321" {
+			t.Errorf("ExtractLink() = %s, %v", link, err)
+		}
+		if code, err := kratox.ExtractCode(m); err != nil || code != "654321" {
+			t.Errorf("ExtractCode() = %s, %v", code, err)
+		}
+	})
+
+	t.Run("wait timeout", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 300*time.Millisecond)
+		defer cancel()
+		if _, err := kratox.WaitForMessage(ctx, "nobody@b.c", time.Now()); err == nil {
+			t.Error("WaitForMessage() expected an error on timeout")
+		}
+	})
+}
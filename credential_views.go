@@ -0,0 +1,223 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	client "github.com/ory/kratos-client-go"
+
+	"github.com/w6d-io/x/errorx"
+	"github.com/w6d-io/x/logx"
+)
+
+// CredentialType is the type of a kratos credential
+type CredentialType string
+
+const (
+	CredentialPassword     CredentialType = "password"
+	CredentialTOTP         CredentialType = "totp"
+	CredentialWebAuthn     CredentialType = "webauthn"
+	CredentialLookupSecret CredentialType = "lookup_secret"
+	CredentialOIDC         CredentialType = "oidc"
+	CredentialCode         CredentialType = "code"
+	CredentialPasskey      CredentialType = "passkey"
+)
+
+// IdentityCredentials is the identity with the typed config of its credentials.
+// A credential is nil when the identity does not hold it or when its type was not requested
+type IdentityCredentials struct {
+	// Identity without its credentials
+	Identity client.Identity
+	// Types are the types of all the credentials held by the identity, sorted
+	Types []string
+
+	Password     *PasswordCredential
+	TOTP         *TOTPCredential
+	WebAuthn     *WebAuthnCredential
+	LookupSecret *LookupSecretCredential
+	OIDC         *OIDCCredential
+	Code         *CodeCredential
+	Passkey      *WebAuthnCredential
+}
+
+// CredentialMeta holds the fields common to all the credentials
+type CredentialMeta struct {
+	Identifiers []string  `json:"identifiers"`
+	Version     int64     `json:"version"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// PasswordCredential is the password credential
+type PasswordCredential struct {
+	CredentialMeta
+	// HashedPassword is the hash of the password
+	HashedPassword string `json:"hashed_password"`
+}
+
+// TOTPCredential is the totp authenticator
+type TOTPCredential struct {
+	CredentialMeta
+	// URL is the otpauth url holding the secret
+	URL string `json:"totp_url"`
+}
+
+// WebAuthnCredential holds the webauthn keys, the passkeys as well
+type WebAuthnCredential struct {
+	CredentialMeta
+	Keys       []WebAuthnKey `json:"credentials"`
+	UserHandle string        `json:"user_handle"`
+}
+
+// WebAuthnKey is a security key or a passkey
+type WebAuthnKey struct {
+	ID             string    `json:"id"`
+	DisplayName    string    `json:"display_name"`
+	IsPasswordless bool      `json:"is_passwordless"`
+	AddedAt        time.Time `json:"added_at"`
+}
+
+// LookupSecretCredential holds the backup codes
+type LookupSecretCredential struct {
+	CredentialMeta
+	Codes []LookupSecretCode `json:"recovery_codes"`
+}
+
+// LookupSecretCode is a backup code, UsedAt is zero until it is used
+type LookupSecretCode struct {
+	Code   string
+	UsedAt time.Time
+}
+
+// OIDCCredential holds the accounts linked with the identity
+type OIDCCredential struct {
+	CredentialMeta
+	Providers []Provider `json:"providers"`
+}
+
+// CodeCredential holds the addresses the one-time codes are sent to
+type CodeCredential struct {
+	CredentialMeta
+	Addresses []CodeAddress `json:"addresses"`
+}
+
+// CodeAddress is an address receiving the one-time codes
+type CodeAddress struct {
+	Channel string `json:"channel"`
+	Address string `json:"address"`
+}
+
+// UnmarshalJSON decodes the used_at field, stored by kratos as a nullable time
+func (c *LookupSecretCode) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Code   string          `json:"code"`
+		UsedAt json.RawMessage `json:"used_at"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	c.Code = raw.Code
+	c.UsedAt = time.Time{}
+	if len(raw.UsedAt) == 0 || string(raw.UsedAt) == "null" {
+		return nil
+	}
+	var nullTime struct {
+		Time  time.Time `json:"Time"`
+		Valid bool      `json:"Valid"`
+	}
+	if err := json.Unmarshal(raw.UsedAt, &nullTime); err == nil {
+		if nullTime.Valid {
+			c.UsedAt = nullTime.Time
+		}
+		return nil
+	}
+	return json.Unmarshal(raw.UsedAt, &c.UsedAt)
+}
+
+// Used reports whether the code was used
+func (c LookupSecretCode) Used() bool {
+	return !c.UsedAt.IsZero()
+}
+
+// GetIdentityWithCredentialTypes gets the identity with the typed config of the credential types.
+// The types unknown by the generated client, as code and passkey, are decoded as well
+// if kratos is unreachable or an other issues, return nil with statusCode of the call and error-go
+func (a auth) GetIdentityWithCredentialTypes(ctx context.Context, id string, types ...CredentialType) (*IdentityCredentials, error) {
+	log := logx.WithName(ctx, "GetIdentityWithCredentialTypes")
+	include := make([]string, 0, len(types))
+	for _, t := range types {
+		include = append(include, string(t))
+	}
+	i, raw, err := a.getIdentityWithCredentials(ctx, id, include...)
+	if err != nil {
+		return nil, err
+	}
+	ic := &IdentityCredentials{Identity: *i}
+	ic.Identity.Credentials = nil
+
+	for t, d := range raw {
+		var c struct {
+			CredentialMeta
+			Config json.RawMessage `json:"config"`
+		}
+		if err := json.Unmarshal(d, &c); err != nil {
+			log.Error(err, "decode credential failed", "type", t)
+			return nil, errorx.NewHTTP(err, http.StatusInternalServerError, "decode credential failed")
+		}
+		ic.Types = append(ic.Types, t)
+		var view interface{}
+		switch CredentialType(t) {
+		case CredentialPassword:
+			ic.Password = &PasswordCredential{CredentialMeta: c.CredentialMeta}
+			view = ic.Password
+		case CredentialTOTP:
+			ic.TOTP = &TOTPCredential{CredentialMeta: c.CredentialMeta}
+			view = ic.TOTP
+		case CredentialWebAuthn:
+			ic.WebAuthn = &WebAuthnCredential{CredentialMeta: c.CredentialMeta}
+			view = ic.WebAuthn
+		case CredentialLookupSecret:
+			ic.LookupSecret = &LookupSecretCredential{CredentialMeta: c.CredentialMeta}
+			view = ic.LookupSecret
+		case CredentialOIDC:
+			ic.OIDC = &OIDCCredential{CredentialMeta: c.CredentialMeta}
+			view = ic.OIDC
+		case CredentialCode:
+			ic.Code = &CodeCredential{CredentialMeta: c.CredentialMeta}
+			view = ic.Code
+		case CredentialPasskey:
+			ic.Passkey = &WebAuthnCredential{CredentialMeta: c.CredentialMeta}
+			view = ic.Passkey
+		default:
+			continue
+		}
+		// the config is only returned for the requested types
+		if len(c.Config) == 0 || string(c.Config) == "null" {
+			continue
+		}
+		if err := json.Unmarshal(c.Config, view); err != nil {
+			log.Error(err, "decode credential config failed", "type", t)
+			return nil, errorx.NewHTTP(err, http.StatusInternalServerError, "decode credential config failed")
+		}
+	}
+	sort.Strings(ic.Types)
+	return ic, nil
+}
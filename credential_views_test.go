@@ -0,0 +1,98 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/w6d-io/kratox"
+)
+
+const identityWithCredentials = `{
+  "id": "identity-id",
+  "schema_id": "default",
+  "schema_url": "",
+  "state": "active",
+  "traits": {"email": "a@b.c"},
+  "credentials": {
+    "password": {"type": "password", "identifiers": ["a@b.c"], "version": 0, "config": {"hashed_password": "$2a$10$hash"}},
+    "totp": {"type": "totp", "identifiers": ["identity-id"], "config": {"totp_url": "otpauth://totp/w6d:a@b.c?secret=ABC"}},
+    "lookup_secret": {"type": "lookup_secret", "identifiers": ["identity-id"], "config": {"recovery_codes": [
+      {"code": "abc", "used_at": {"Time": "2026-01-02T03:04:05Z", "Valid": true}},
+      {"code": "def", "used_at": {"Time": "0001-01-01T00:00:00Z", "Valid": false}},
+      {"code": "ghi"}
+    ]}},
+    "webauthn": {"type": "webauthn", "identifiers": ["identity-id"], "config": {"credentials": [{"id": "a2V5", "display_name": "yubikey", "is_passwordless": false, "added_at": "2026-01-02T03:04:05Z"}], "user_handle": "aGFuZGxl"}},
+    "oidc": {"type": "oidc", "identifiers": ["google:sub"], "config": {"providers": [{"provider": "google", "subject": "sub", "organization": "w6d"}]}},
+    "code": {"type": "code", "identifiers": ["a@b.c"], "config": {"addresses": [{"channel": "email", "address": "a@b.c"}]}},
+    "passkey": {"type": "passkey", "identifiers": ["aGFuZGxl"], "config": {"credentials": [{"id": "cGFzcw", "display_name": "phone", "is_passwordless": true}], "user_handle": "aGFuZGxl"}}
+  }
+}`
+
+func TestGetIdentityWithCredentialTypes(t *testing.T) {
+	var query []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()["include_credential"]
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(identityWithCredentials))
+	}))
+	defer srv.Close()
+	kratox.SetAddress(srv.URL, srv.URL)
+
+	got, err := kratox.Kratox.GetIdentityWithCredentialTypes(context.Background(), "identity-id",
+		kratox.CredentialPassword, kratox.CredentialTOTP, kratox.CredentialLookupSecret, kratox.CredentialWebAuthn,
+		kratox.CredentialOIDC, kratox.CredentialCode, kratox.CredentialPasskey)
+	if err != nil {
+		t.Fatalf("GetIdentityWithCredentialTypes() error = %v", err)
+	}
+	if len(query) != 7 {
+		t.Errorf("include_credential = %v", query)
+	}
+	used := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{name: "identity", got: got.Identity.Id, want: "identity-id"},
+		{name: "credentials removed from identity", got: got.Identity.Credentials == nil, want: true},
+		{name: "types", got: got.Types, want: []string{"code", "lookup_secret", "oidc", "passkey", "password", "totp", "webauthn"}},
+		{name: "password", got: got.Password.HashedPassword, want: "$2a$10$hash"},
+		{name: "password identifiers", got: got.Password.Identifiers, want: []string{"a@b.c"}},
+		{name: "totp", got: got.TOTP.URL, want: "otpauth://totp/w6d:a@b.c?secret=ABC"},
+		{name: "lookup secret", got: got.LookupSecret.Codes, want: []kratox.LookupSecretCode{{Code: "abc", UsedAt: used}, {Code: "def"}, {Code: "ghi"}}},
+		{name: "webauthn", got: got.WebAuthn.Keys, want: []kratox.WebAuthnKey{{ID: "a2V5", DisplayName: "yubikey", AddedAt: used}}},
+		{name: "oidc", got: got.OIDC.Providers, want: []kratox.Provider{{Provider: "google", Subject: "sub", Organization: "w6d"}}},
+		{name: "code", got: got.Code.Addresses, want: []kratox.CodeAddress{{Channel: "email", Address: "a@b.c"}}},
+		{name: "passkey", got: got.Passkey.Keys[0].IsPasswordless, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.got, tt.want) {
+				t.Errorf("got %+v, want %+v", tt.got, tt.want)
+			}
+		})
+	}
+	if !got.LookupSecret.Codes[0].Used() || got.LookupSecret.Codes[1].Used() {
+		t.Error("Used() mismatch")
+	}
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox
+
+import (
+	"context"
+	"net/http"
+
+	client "github.com/ory/kratos-client-go"
+
+	"github.com/w6d-io/x/errorx"
+	"github.com/w6d-io/x/logx"
+)
+
+var (
+	// ErrCredentialNotFound is returned when the identity does not hold the credential
+	ErrCredentialNotFound = errorx.New("credential not found")
+
+	errWebAuthnKeyRemoval = errorx.New("kratos cannot remove a single webauthn key of an identity holding several")
+	errPasswordlessKeys   = errorx.New("webauthn second factor keys held along with passwordless keys")
+)
+
+// mfaCredentials are the second factor credentials removed by ResetMFA
+var mfaCredentials = []client.IdentityCredentialsType{
+	client.IDENTITYCREDENTIALSTYPE_TOTP,
+	client.IDENTITYCREDENTIALSTYPE_LOOKUP_SECRET,
+	client.IDENTITYCREDENTIALSTYPE_WEBAUTHN,
+}
+
+// GetCredentialTypes returns the sorted types of the credentials held by the identity
+// if kratos is unreachable or an other issues, return nil with statusCode of the call and error-go
+func (a auth) GetCredentialTypes(ctx context.Context, id string) ([]string, error) {
+	ic, err := a.GetIdentityWithCredentialTypes(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return ic.Types, nil
+}
+
+// DeleteCredential removes the credential type from the identity.
+// Kratos only removes the second factor ones (totp, webauthn, lookup_secret)
+// if kratos is unreachable or an other issues, return statusCode of the call and error-go
+func (a auth) DeleteCredential(ctx context.Context, id string, credentialType client.IdentityCredentialsType) error {
+	log := logx.WithName(ctx, "DeleteCredential")
+	api, err := a.adminAPI()
+	if err != nil {
+		return err
+	}
+	r, err := api.IdentityApi.DeleteIdentityCredentials(ctx, id, string(credentialType)).Execute()
+	if err != nil {
+		log.Error(err, "calling fail", "name", "DeleteIdentityCredentials", "response", r)
+		return errorx.NewHTTP(err, statusOf(r, err), "fail to call kratos")
+	}
+	log.V(1).Info("credential deleted", "id", id, "type", credentialType)
+	return nil
+}
+
+// DeleteWebAuthnKey removes the webauthn key from the identity.
+// Kratos removes the webauthn credential as a whole and does not import it, so the key is only
+// removed when it is the last one, the 501 status is returned otherwise and the user has to
+// remove it from the settings.
+// It returns ErrCredentialNotFound with the 404 status when the identity does not hold the key
+// if kratos is unreachable or an other issues, return statusCode of the call and error-go
+func (a auth) DeleteWebAuthnKey(ctx context.Context, id, keyID string) error {
+	log := logx.WithName(ctx, "DeleteWebAuthnKey")
+	ic, err := a.GetIdentityWithCredentialTypes(ctx, id, CredentialWebAuthn)
+	if err != nil {
+		return err
+	}
+	var keys []WebAuthnKey
+	if ic.WebAuthn != nil {
+		keys = ic.WebAuthn.Keys
+	}
+	found := false
+	for _, k := range keys {
+		if k.ID == keyID {
+			found = true
+		}
+	}
+	switch {
+	case !found:
+		log.Error(ErrCredentialNotFound, "delete webauthn key failed", "key", keyID)
+		return errorx.NewHTTP(ErrCredentialNotFound, http.StatusNotFound, "webauthn key not found")
+	case len(keys) > 1:
+		log.Error(errWebAuthnKeyRemoval, "delete webauthn key failed", "key", keyID, "keys", len(keys))
+		return errorx.NewHTTP(errWebAuthnKeyRemoval, http.StatusNotImplemented, "webauthn key cannot be removed alone")
+	}
+	return a.DeleteCredential(ctx, id, client.IDENTITYCREDENTIALSTYPE_WEBAUTHN)
+}
+
+// ResetMFA removes the second factor credentials of the identity, its totp device, backup codes
+// and webauthn keys, so it logs in again with the first factor only. The passwordless webauthn
+// keys are a first factor: the webauthn credential is kept when all its keys are passwordless,
+// and the 409 status is returned before removing anything when it holds both kinds, kratos
+// only removes the webauthn keys as a whole
+// if kratos is unreachable or an other issues, return statusCode of the call and error-go
+func (a auth) ResetMFA(ctx context.Context, id string) error {
+	log := logx.WithName(ctx, "ResetMFA")
+	ic, err := a.GetIdentityWithCredentialTypes(ctx, id, CredentialWebAuthn)
+	if err != nil {
+		return err
+	}
+	held := map[string]bool{}
+	for _, t := range ic.Types {
+		held[t] = true
+	}
+	if ic.WebAuthn != nil {
+		passwordless := 0
+		for _, k := range ic.WebAuthn.Keys {
+			if k.IsPasswordless {
+				passwordless++
+			}
+		}
+		switch {
+		case passwordless > 0 && passwordless == len(ic.WebAuthn.Keys):
+			held[string(CredentialWebAuthn)] = false
+		case passwordless > 0:
+			log.Error(errPasswordlessKeys, "reset second factors failed", "id", id)
+			return errorx.NewHTTP(errPasswordlessKeys, http.StatusConflict, "webauthn keys cannot be removed without the passwordless ones")
+		}
+	}
+	for _, t := range mfaCredentials {
+		if !held[string(t)] {
+			continue
+		}
+		if err := a.DeleteCredential(ctx, id, t); err != nil {
+			return err
+		}
+	}
+	log.V(1).Info("second factors removed", "id", id)
+	return nil
+}
@@ -0,0 +1,234 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	client "github.com/ory/kratos-client-go"
+
+	"github.com/w6d-io/kratox"
+	"github.com/w6d-io/x/errorx"
+)
+
+// fakeCredentials is a kratos admin api holding the credentials of one identity
+type fakeCredentials struct {
+	mu          sync.Mutex
+	credentials map[string]interface{}
+	deleted     []string
+	updated     int
+}
+
+func (f *fakeCredentials) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/admin/identities/"), "/")
+	switch {
+	case r.Method == http.MethodGet && len(parts) == 1:
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"id": parts[0], "schema_id": "default", "schema_url": "", "traits": map[string]string{},
+			"credentials": f.credentials,
+		})
+	case r.Method == http.MethodDelete && len(parts) == 3:
+		if _, ok := f.credentials[parts[2]]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]interface{}{"code": 404, "message": "not found"}})
+			return
+		}
+		delete(f.credentials, parts[2])
+		f.deleted = append(f.deleted, parts[2])
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut && len(parts) == 1:
+		// kratos only imports the password and oidc credentials
+		var body struct {
+			Credentials map[string]json.RawMessage `json:"credentials"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for t := range body.Credentials {
+			if t != "password" && t != "oidc" {
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]interface{}{"code": 400, "message": "credentials " + t + " cannot be imported"}})
+				return
+			}
+		}
+		f.updated++
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"id": parts[0], "schema_id": "default", "schema_url": "", "traits": map[string]string{},
+		})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// webauthn returns the webauthn credential holding the keys, the keys prefixed with passwordless are a first factor
+func webauthn(keys ...string) map[string]interface{} {
+	var creds []interface{}
+	for _, k := range keys {
+		creds = append(creds, map[string]interface{}{
+			"id": k, "display_name": k, "is_passwordless": strings.HasPrefix(k, "passwordless"), "public_key": "key",
+		})
+	}
+	return map[string]interface{}{"type": "webauthn", "config": map[string]interface{}{"credentials": creds, "user_handle": "handle"}}
+}
+
+// webauthnKeys returns the ids of the webauthn keys held by the fake, nil without webauthn credential
+func (f *fakeCredentials) webauthnKeys(t *testing.T) []string {
+	t.Helper()
+	c, ok := f.credentials["webauthn"]
+	if !ok {
+		return nil
+	}
+	d, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var w struct {
+		Config struct {
+			Credentials []struct {
+				ID        string `json:"id"`
+				PublicKey string `json:"public_key"`
+			} `json:"credentials"`
+			UserHandle string `json:"user_handle"`
+		} `json:"config"`
+	}
+	if err := json.Unmarshal(d, &w); err != nil {
+		t.Fatal(err)
+	}
+	if w.Config.UserHandle != "handle" {
+		t.Errorf("webauthn user handle = %q", w.Config.UserHandle)
+	}
+	keys := []string{}
+	for _, k := range w.Config.Credentials {
+		if k.PublicKey != "key" {
+			t.Errorf("webauthn key %s lost its public key", k.ID)
+		}
+		keys = append(keys, k.ID)
+	}
+	return keys
+}
+
+func TestCredentials(t *testing.T) {
+	fake := &fakeCredentials{credentials: map[string]interface{}{
+		"password":      map[string]interface{}{"type": "password", "identifiers": []string{"a@b.c"}},
+		"totp":          map[string]interface{}{"type": "totp"},
+		"lookup_secret": map[string]interface{}{"type": "lookup_secret"},
+		"webauthn":      webauthn("key-1", "key-2"),
+	}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	kratox.SetAddress(srv.URL, srv.URL)
+	ctx := context.Background()
+
+	types, err := kratox.Kratox.GetCredentialTypes(ctx, "id")
+	if err != nil || !reflect.DeepEqual(types, []string{"lookup_secret", "password", "totp", "webauthn"}) {
+		t.Fatalf("GetCredentialTypes() = %v, %v", types, err)
+	}
+
+	tests := []struct {
+		name       string
+		keyID      string
+		keys       []string
+		wantKeys   []string
+		wantStatus int
+	}{
+		{name: "unknown key", keyID: "key-3", keys: []string{"key-1", "key-2"}, wantKeys: []string{"key-1", "key-2"}, wantStatus: http.StatusNotFound},
+		{name: "one key among several", keyID: "key-1", keys: []string{"key-1", "key-2"}, wantKeys: []string{"key-1", "key-2"}, wantStatus: http.StatusNotImplemented},
+		{name: "last key", keyID: "key-1", keys: []string{"key-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake.credentials["webauthn"] = webauthn(tt.keys...)
+			err := kratox.Kratox.DeleteWebAuthnKey(ctx, "id", tt.keyID)
+			if tt.wantStatus != 0 {
+				var e *errorx.Error
+				if !errors.As(err, &e) || e.StatusCode != tt.wantStatus {
+					t.Errorf("DeleteWebAuthnKey() error = %v, want status %d", err, tt.wantStatus)
+				}
+			} else if err != nil {
+				t.Fatalf("DeleteWebAuthnKey() error = %v", err)
+			}
+			if got := fake.webauthnKeys(t); !reflect.DeepEqual(got, tt.wantKeys) {
+				t.Errorf("DeleteWebAuthnKey() kept the keys %v, want %v", got, tt.wantKeys)
+			}
+		})
+	}
+
+	fake.deleted = nil
+	fake.credentials["webauthn"] = webauthn("key-1")
+	if err := kratox.Kratox.ResetMFA(ctx, "id"); err != nil {
+		t.Fatalf("ResetMFA() error = %v", err)
+	}
+	if !reflect.DeepEqual(fake.deleted, []string{"totp", "lookup_secret", "webauthn"}) {
+		t.Errorf("ResetMFA() deleted %v", fake.deleted)
+	}
+	if err := kratox.Kratox.DeleteCredential(ctx, "id", client.IDENTITYCREDENTIALSTYPE_TOTP); err == nil {
+		t.Error("DeleteCredential() expected an error for a missing credential")
+	}
+}
+
+func TestResetMFA_Passwordless(t *testing.T) {
+	tests := []struct {
+		name        string
+		keys        []string
+		wantStatus  int
+		wantDeleted []string
+		wantKeys    []string
+	}{
+		{name: "passwordless keys only", keys: []string{"passwordless-1", "passwordless-2"},
+			wantDeleted: []string{"totp"}, wantKeys: []string{"passwordless-1", "passwordless-2"}},
+		{name: "passwordless and second factor keys", keys: []string{"key-1", "passwordless-1"},
+			wantStatus: http.StatusConflict, wantKeys: []string{"key-1", "passwordless-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeCredentials{credentials: map[string]interface{}{
+				"totp":     map[string]interface{}{"type": "totp"},
+				"webauthn": webauthn(tt.keys...),
+			}}
+			srv := httptest.NewServer(fake)
+			defer srv.Close()
+			kratox.SetAddress(srv.URL, srv.URL)
+
+			err := kratox.Kratox.ResetMFA(context.Background(), "id")
+			if tt.wantStatus != 0 {
+				var e *errorx.Error
+				if !errors.As(err, &e) || e.StatusCode != tt.wantStatus {
+					t.Errorf("ResetMFA() error = %v, want status %d", err, tt.wantStatus)
+				}
+			} else if err != nil {
+				t.Fatalf("ResetMFA() error = %v", err)
+			}
+			if !reflect.DeepEqual(fake.deleted, tt.wantDeleted) || fake.updated != 0 {
+				t.Errorf("ResetMFA() deleted %v and updated %d times, want %v deleted", fake.deleted, fake.updated, tt.wantDeleted)
+			}
+			if got := fake.webauthnKeys(t); !reflect.DeepEqual(got, tt.wantKeys) {
+				t.Errorf("ResetMFA() kept the webauthn keys %v, want %v", got, tt.wantKeys)
+			}
+		})
+	}
+}
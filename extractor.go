@@ -0,0 +1,233 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox
+
+import (
+	"context"
+	"net/http"
+
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// SessionTokenHeader is the header where api clients send their session token
+	SessionTokenHeader = "X-Session-Token"
+)
+
+// Credential is what identifies the session to kratos, either the session cookie
+// of browsers or the session token of api clients
+type Credential struct {
+	// Cookie is the value of the session cookie
+	Cookie string
+	// Token is the session token
+	Token string
+}
+
+// SessionExtractor reads the session credential from an incoming request
+type SessionExtractor interface {
+	// FromHTTP returns the credential of the http request, false when not found
+	FromHTTP(*http.Request) (Credential, bool)
+	// FromGRPC returns the credential of the incoming gRPC metadata, false when not found
+	FromGRPC(context.Context) (Credential, bool)
+}
+
+// ExtractorChain tries the extractors in order, the first credential found wins
+type ExtractorChain []SessionExtractor
+
+// CookieExtractor reads the session cookie, from the cookie header of http requests
+// and from the cookie metadata of gRPC calls
+type CookieExtractor struct {
+	// Name of the cookie, CookieName when empty
+	Name string
+}
+
+// HeaderExtractor reads the session token from a header or from the metadata with the same name
+type HeaderExtractor struct {
+	// Name of the header, SessionTokenHeader when empty
+	Name string
+}
+
+// BearerExtractor reads the session token from the authorization bearer header or metadata
+type BearerExtractor struct{}
+
+// QueryExtractor reads the session token from a query parameter of http requests,
+// browsers cannot set headers on websockets
+type QueryExtractor struct {
+	// Param is the query parameter name
+	Param string
+}
+
+// MetadataExtractor reads the session cookie value forwarded as a gRPC metadata key
+type MetadataExtractor struct {
+	// Key of the metadata, CookieName when empty
+	Key string
+}
+
+var (
+	// Extractors reads the session credential wherever a session is resolved
+	Extractors SessionExtractor = DefaultExtractors()
+)
+
+// DefaultExtractors reads the kratos session cookie from http requests and from gRPC metadata
+func DefaultExtractors() ExtractorChain {
+	return ExtractorChain{CookieExtractor{}, MetadataExtractor{}}
+}
+
+// SetExtractors replaces the extractors used to resolve the sessions by the chain
+func SetExtractors(extractors ...SessionExtractor) {
+	Extractors = ExtractorChain(extractors)
+}
+
+// IsZero reports whether the credential is empty
+func (c Credential) IsZero() bool {
+	return c.Cookie == "" && c.Token == ""
+}
+
+// FromHTTP implements SessionExtractor
+func (c ExtractorChain) FromHTTP(r *http.Request) (Credential, bool) {
+	for _, e := range c {
+		if cred, ok := e.FromHTTP(r); ok {
+			return cred, true
+		}
+	}
+	return Credential{}, false
+}
+
+// FromGRPC implements SessionExtractor
+func (c ExtractorChain) FromGRPC(ctx context.Context) (Credential, bool) {
+	for _, e := range c {
+		if cred, ok := e.FromGRPC(ctx); ok {
+			return cred, true
+		}
+	}
+	return Credential{}, false
+}
+
+// FromHTTP implements SessionExtractor
+func (e CookieExtractor) FromHTTP(r *http.Request) (Credential, bool) {
+	cookie, err := r.Cookie(e.name())
+	if err != nil || cookie.Value == "" {
+		return Credential{}, false
+	}
+	return Credential{Cookie: cookie.Value}, true
+}
+
+// FromGRPC implements SessionExtractor
+func (e CookieExtractor) FromGRPC(ctx context.Context) (Credential, bool) {
+	values := metadataValues(ctx, "cookie")
+	if len(values) == 0 {
+		return Credential{}, false
+	}
+	r := &http.Request{Header: http.Header{"Cookie": values}}
+	return e.FromHTTP(r)
+}
+
+func (e CookieExtractor) name() string {
+	if e.Name == "" {
+		return CookieName
+	}
+	return e.Name
+}
+
+// FromHTTP implements SessionExtractor
+func (e HeaderExtractor) FromHTTP(r *http.Request) (Credential, bool) {
+	if v := r.Header.Get(e.name()); v != "" {
+		return Credential{Token: v}, true
+	}
+	return Credential{}, false
+}
+
+// FromGRPC implements SessionExtractor
+func (e HeaderExtractor) FromGRPC(ctx context.Context) (Credential, bool) {
+	if values := metadataValues(ctx, e.name()); len(values) > 0 && values[0] != "" {
+		return Credential{Token: values[0]}, true
+	}
+	return Credential{}, false
+}
+
+func (e HeaderExtractor) name() string {
+	if e.Name == "" {
+		return SessionTokenHeader
+	}
+	return e.Name
+}
+
+// FromHTTP implements SessionExtractor
+func (BearerExtractor) FromHTTP(r *http.Request) (Credential, bool) {
+	if token := bearer(r.Header.Get("Authorization")); token != "" {
+		return Credential{Token: token}, true
+	}
+	return Credential{}, false
+}
+
+// FromGRPC implements SessionExtractor
+func (BearerExtractor) FromGRPC(ctx context.Context) (Credential, bool) {
+	if values := metadataValues(ctx, "authorization"); len(values) > 0 {
+		if token := bearer(values[0]); token != "" {
+			return Credential{Token: token}, true
+		}
+	}
+	return Credential{}, false
+}
+
+// FromHTTP implements SessionExtractor
+func (e QueryExtractor) FromHTTP(r *http.Request) (Credential, bool) {
+	if r.URL == nil || e.Param == "" {
+		return Credential{}, false
+	}
+	if v := r.URL.Query().Get(e.Param); v != "" {
+		return Credential{Token: v}, true
+	}
+	return Credential{}, false
+}
+
+// FromGRPC implements SessionExtractor, gRPC calls have no query parameters
+func (QueryExtractor) FromGRPC(context.Context) (Credential, bool) {
+	return Credential{}, false
+}
+
+// FromHTTP implements SessionExtractor, http requests have no metadata
+func (MetadataExtractor) FromHTTP(*http.Request) (Credential, bool) {
+	return Credential{}, false
+}
+
+// FromGRPC implements SessionExtractor
+func (e MetadataExtractor) FromGRPC(ctx context.Context) (Credential, bool) {
+	key := e.Key
+	if key == "" {
+		key = CookieName
+	}
+	if values := metadataValues(ctx, key); len(values) > 0 && values[0] != "" {
+		return Credential{Cookie: values[0]}, true
+	}
+	return Credential{}, false
+}
+
+// extractor returns the configured extractors, the default ones when unset
+func extractor() SessionExtractor {
+	if Extractors == nil {
+		return DefaultExtractors()
+	}
+	return Extractors
+}
+
+func metadataValues(ctx context.Context, key string) []string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil
+	}
+	return md.Get(key)
+}
@@ -0,0 +1,218 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+
+	"github.com/w6d-io/kratox"
+)
+
+func TestExtractorChain_FromHTTP(t *testing.T) {
+	chain := kratox.ExtractorChain{
+		kratox.CookieExtractor{Name: "custom_session"},
+		kratox.HeaderExtractor{},
+		kratox.BearerExtractor{},
+		kratox.QueryExtractor{Param: "session_token"},
+	}
+	tests := []struct {
+		name   string
+		setup  func(r *http.Request)
+		target string
+		want   kratox.Credential
+		ok     bool
+	}{
+		{
+			name:  "cookie",
+			setup: func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "custom_session", Value: "c"}) },
+			want:  kratox.Credential{Cookie: "c"},
+			ok:    true,
+		},
+		{
+			name:  "header",
+			setup: func(r *http.Request) { r.Header.Set(kratox.SessionTokenHeader, "t") },
+			want:  kratox.Credential{Token: "t"},
+			ok:    true,
+		},
+		{
+			name:  "bearer",
+			setup: func(r *http.Request) { r.Header.Set("Authorization", "Bearer t") },
+			want:  kratox.Credential{Token: "t"},
+			ok:    true,
+		},
+		{
+			name:   "query",
+			target: "/ws?session_token=t",
+			want:   kratox.Credential{Token: "t"},
+			ok:     true,
+		},
+		{
+			name: "order",
+			setup: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer t2")
+				r.AddCookie(&http.Cookie{Name: "custom_session", Value: "c"})
+			},
+			want: kratox.Credential{Cookie: "c"},
+			ok:   true,
+		},
+		{
+			name:  "default cookie name is ignored",
+			setup: func(r *http.Request) { r.AddCookie(&http.Cookie{Name: kratox.CookieName, Value: "c"}) },
+			ok:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := tt.target
+			if target == "" {
+				target = "/"
+			}
+			r := httptest.NewRequest(http.MethodGet, target, nil)
+			if tt.setup != nil {
+				tt.setup(r)
+			}
+			got, ok := chain.FromHTTP(r)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("FromHTTP() = %v, %v, want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestExtractorChain_FromGRPC(t *testing.T) {
+	tests := []struct {
+		name  string
+		chain kratox.ExtractorChain
+		md    metadata.MD
+		want  kratox.Credential
+		ok    bool
+	}{
+		{
+			name:  "legacy metadata key",
+			chain: kratox.DefaultExtractors(),
+			md:    metadata.Pairs(kratox.CookieName, "c"),
+			want:  kratox.Credential{Cookie: "c"},
+			ok:    true,
+		},
+		{
+			name:  "cookie metadata",
+			chain: kratox.DefaultExtractors(),
+			md:    metadata.Pairs("cookie", "a=b; "+kratox.CookieName+"=c"),
+			want:  kratox.Credential{Cookie: "c"},
+			ok:    true,
+		},
+		{
+			name:  "custom metadata key",
+			chain: kratox.ExtractorChain{kratox.MetadataExtractor{Key: "session"}},
+			md:    metadata.Pairs("session", "c"),
+			want:  kratox.Credential{Cookie: "c"},
+			ok:    true,
+		},
+		{
+			name:  "session token",
+			chain: kratox.ExtractorChain{kratox.HeaderExtractor{}, kratox.BearerExtractor{}},
+			md:    metadata.Pairs("authorization", "Bearer t"),
+			want:  kratox.Credential{Token: "t"},
+			ok:    true,
+		},
+		{
+			name:  "nothing",
+			chain: kratox.ExtractorChain{kratox.QueryExtractor{Param: "session_token"}},
+			md:    metadata.Pairs(kratox.CookieName, "c"),
+			ok:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.chain.FromGRPC(metadata.NewIncomingContext(context.Background(), tt.md))
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("FromGRPC() = %v, %v, want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestGetSessionFromHTTP_SessionToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(kratox.SessionTokenHeader) != "ory_st_token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":       "session-id",
+			"identity": map[string]interface{}{"id": "identity-id", "schema_id": "default", "schema_url": "", "traits": map[string]string{}},
+		})
+	}))
+	defer srv.Close()
+	kratox.SetAddress(srv.URL, srv.URL)
+	kratox.SetExtractors(kratox.CookieExtractor{}, kratox.BearerExtractor{})
+	defer kratox.SetExtractors(kratox.DefaultExtractors()...)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer ory_st_token")
+	sess, err := kratox.Kratox.GetSessionFromHTTP(context.Background(), r)
+	if err != nil || sess.Identity.Id != "identity-id" {
+		t.Errorf("GetSessionFromHTTP() = %v, %v", sess, err)
+	}
+	ctx, err := kratox.SetCookieFromHttpToCtx(context.Background(), r)
+	if err != nil || kratox.GetSessionTokenFromCtx(ctx) != "ory_st_token" {
+		t.Errorf("SetCookieFromHttpToCtx() did not record the session token: %v", err)
+	}
+}
+
+func TestGetSessionFromHTTP_CookieName(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("custom_kratos"); err != nil || c.Value != "test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":       "session-id",
+			"identity": map[string]interface{}{"id": "identity-id", "schema_id": "default", "schema_url": "", "traits": map[string]string{}},
+		})
+	}))
+	defer srv.Close()
+	kratox.SetExtractors(kratox.CookieExtractor{Name: "custom"})
+	defer kratox.SetExtractors(kratox.DefaultExtractors()...)
+
+	tests := []struct {
+		name    string
+		conn    kratox.Conn
+		wantErr bool
+	}{
+		{name: "kratos cookie name", conn: kratox.Conn{Address: srv.URL, AdminAddress: srv.URL, CookieName: "custom_kratos"}},
+		{name: "default kratos cookie name", conn: kratox.Conn{Address: srv.URL, AdminAddress: srv.URL}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kratox.SetConn(tt.conn)
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.AddCookie(&http.Cookie{Name: "custom", Value: "test"})
+			_, err := kratox.Kratox.GetSessionFromHTTP(context.Background(), r)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetSessionFromHTTP() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
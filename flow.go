@@ -0,0 +1,290 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	client "github.com/ory/kratos-client-go"
+
+	"github.com/w6d-io/x/errorx"
+	"github.com/w6d-io/x/logx"
+)
+
+const (
+	// sessionRefreshRequired is the kratos error id when the session is not privileged anymore
+	sessionRefreshRequired = "session_refresh_required"
+)
+
+// FlowError is returned when kratos rejects a self-service flow submission.
+// It holds the messages of the flow form, the global ones and the ones attached to a field
+type FlowError struct {
+	// StatusCode of the kratos response
+	StatusCode int
+	// FlowID is the id of the flow to submit again
+	FlowID string
+	// State of the flow when kratos reports it (ex: sent_email)
+	State string
+	// Messages are the messages not attached to a field
+	Messages []client.UiText
+	// Fields holds the messages by field name
+	Fields map[string][]client.UiText
+	// Form is the whole form of the flow to render it again
+	Form *Form
+}
+
+// Error implements error
+func (e *FlowError) Error() string {
+	var texts []string
+	for _, m := range e.Messages {
+		texts = append(texts, m.Text)
+	}
+	for field, messages := range e.Fields {
+		for _, m := range messages {
+			texts = append(texts, field+": "+m.Text)
+		}
+	}
+	if len(texts) == 0 {
+		return fmt.Sprintf("flow %s rejected", e.FlowID)
+	}
+	return fmt.Sprintf("flow %s rejected: %s", e.FlowID, strings.Join(texts, ", "))
+}
+
+// HasMessage reports whether the flow holds the message id, globally or on a field
+func (e *FlowError) HasMessage(id int64) bool {
+	for _, m := range e.Messages {
+		if m.Id == id {
+			return true
+		}
+	}
+	for _, messages := range e.Fields {
+		for _, m := range messages {
+			if m.Id == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// PrivilegedSessionError is returned when the flow requires a recently authenticated session,
+// the user has to log in again with the refresh option before submitting it
+type PrivilegedSessionError struct {
+	// RedirectBrowserTo is the login flow url to send browsers to
+	RedirectBrowserTo string
+	// Cause is the kratos response
+	Cause error
+}
+
+// Error implements error
+func (e *PrivilegedSessionError) Error() string {
+	return "privileged session required"
+}
+
+// Unwrap returns the kratos response
+func (e *PrivilegedSessionError) Unwrap() error {
+	return e.Cause
+}
+
+// flowBody is the part of the flows used to report errors.
+// The generated client is not used to decode it as it drops the node attributes
+type flowBody struct {
+	ID    string `json:"id"`
+	State string `json:"state"`
+	UI    struct {
+		Messages []client.UiText `json:"messages"`
+		Nodes    []struct {
+			Attributes struct {
+				ID    string         `json:"id"`
+				Name  string         `json:"name"`
+				Value interface{}    `json:"value"`
+				Text  *client.UiText `json:"text"`
+			} `json:"attributes"`
+			Messages []client.UiText `json:"messages"`
+		} `json:"nodes"`
+	} `json:"ui"`
+}
+
+// genericErrorBody is the kratos error payload
+type genericErrorBody struct {
+	Error struct {
+		ID      string `json:"id"`
+		Code    int    `json:"code"`
+		Reason  string `json:"reason"`
+		Message string `json:"message"`
+	} `json:"error"`
+	UseFlowID         string `json:"use_flow_id"`
+	RedirectBrowserTo string `json:"redirect_browser_to"`
+}
+
+// newFlowError builds the flow error from the flow returned by kratos, nil when there is no flow
+func newFlowError(status int, body []byte) *FlowError {
+	var f flowBody
+	if err := json.Unmarshal(body, &f); err != nil || f.ID == "" {
+		return nil
+	}
+	e := &FlowError{
+		StatusCode: status,
+		FlowID:     f.ID,
+		State:      f.State,
+		Messages:   f.UI.Messages,
+		Fields:     map[string][]client.UiText{},
+	}
+	for _, n := range f.UI.Nodes {
+		if len(n.Messages) == 0 {
+			continue
+		}
+		if n.Attributes.Name == "" {
+			e.Messages = append(e.Messages, n.Messages...)
+			continue
+		}
+		e.Fields[n.Attributes.Name] = append(e.Fields[n.Attributes.Name], n.Messages...)
+	}
+	e.Form, _ = ParseForm(body)
+	return e
+}
+
+// value returns the value of the input node with the name
+func (f flowBody) value(name string) interface{} {
+	for _, n := range f.UI.Nodes {
+		if n.Attributes.Name == name {
+			return n.Attributes.Value
+		}
+	}
+	return nil
+}
+
+// flowCall sends a request to the kratos public api and decodes the json response into out.
+// A rejected flow is returned as a *FlowError, the other failures as errorx errors
+func (a auth) flowCall(ctx context.Context, method, path string, query url.Values, cred Credential, body, out interface{}) error {
+	_, err := a.flowExchange(ctx, method, path, query, cred, nil, body, out)
+	return err
+}
+
+// flowExchange is flowCall sending the cookies along with the session credential and returning
+// the cookies set by kratos, browser flows are protected by a csrf cookie
+func (a auth) flowExchange(ctx context.Context, method, path string, query url.Values, cred Credential, cookies []*http.Cookie, body, out interface{}) ([]*http.Cookie, error) {
+	u, err := a.getKratosAddress()
+	if err != nil {
+		return nil, errorx.NewHTTP(err, http.StatusInternalServerError, "fail to get kratos address")
+	}
+	rsp, err := a.exchange(ctx, u, method, path, query, cred, cookies, body, out)
+	if err != nil {
+		return nil, err
+	}
+	return rsp.Cookies(), nil
+}
+
+// adminCall is flowCall on the kratos admin api, for the endpoints missing from the generated client
+func (a auth) adminCall(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	u, err := a.getKratosAdminAddress()
+	if err != nil {
+		return errorx.NewHTTP(err, http.StatusInternalServerError, "fail to get kratos address")
+	}
+	_, err = a.exchange(ctx, u, method, path, query, Credential{}, nil, body, out)
+	return err
+}
+
+// adminPage is adminCall getting a page of a listing, it returns the query of the next page
+// read from the pagination links, nil on the last page
+func (a auth) adminPage(ctx context.Context, path string, query url.Values, out interface{}) (url.Values, error) {
+	u, err := a.getKratosAdminAddress()
+	if err != nil {
+		return nil, errorx.NewHTTP(err, http.StatusInternalServerError, "fail to get kratos address")
+	}
+	rsp, err := a.exchange(ctx, u, http.MethodGet, path, query, Credential{}, nil, nil, out)
+	if err != nil {
+		return nil, err
+	}
+	return nextLinkQuery(rsp.Header), nil
+}
+
+// exchange sends the json request to the kratos api at u and decodes the json response.
+// The response is returned for its header, its body is already read
+func (k Conn) exchange(ctx context.Context, u *url.URL, method, path string, query url.Values, cred Credential, cookies []*http.Cookie, body, out interface{}) (*http.Response, error) {
+	log := logx.WithName(ctx, "flowCall")
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	u.RawQuery = query.Encode()
+	var reader io.Reader
+	if body != nil {
+		d, err := json.Marshal(body)
+		if err != nil {
+			return nil, errorx.NewHTTP(err, http.StatusInternalServerError, "marshal flow body failed")
+		}
+		reader = bytes.NewReader(d)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), reader)
+	if err != nil {
+		return nil, errorx.NewHTTP(err, http.StatusInternalServerError, "build request failed")
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if cred.Token != "" {
+		req.Header.Set(SessionTokenHeader, cred.Token)
+	}
+	if cred.Cookie != "" {
+		req.AddCookie(&http.Cookie{Name: k.sessionCookie(), Value: cred.Cookie})
+	}
+	for _, c := range cookies {
+		req.AddCookie(&http.Cookie{Name: c.Name, Value: c.Value})
+	}
+	rsp, err := httpClient.Do(req)
+	if err != nil {
+		log.Error(err, "calling fail", "path", path)
+		return nil, errorx.NewHTTP(err, statusOf(nil, err), "fail to call kratos")
+	}
+	defer rsp.Body.Close()
+	data, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, errorx.NewHTTP(err, http.StatusInternalServerError, "read kratos response failed")
+	}
+	if rsp.StatusCode >= http.StatusMultipleChoices {
+		if fe := newFlowError(rsp.StatusCode, data); fe != nil {
+			log.V(1).Info("flow rejected", "path", path, "flow", fe.FlowID, "state", fe.State)
+			return nil, fe
+		}
+		var g genericErrorBody
+		_ = json.Unmarshal(data, &g)
+		err := fmt.Errorf("kratos responded %s: %s", rsp.Status, g.Error.Reason)
+		log.Error(err, "calling fail", "path", path, "id", g.Error.ID)
+		if g.Error.ID == sessionRefreshRequired {
+			return nil, &PrivilegedSessionError{RedirectBrowserTo: g.RedirectBrowserTo, Cause: err}
+		}
+		return nil, &errorx.Error{
+			Cause:      err,
+			StatusCode: rsp.StatusCode,
+			Code:       g.Error.ID,
+			Message:    g.Error.Message,
+		}
+	}
+	if out == nil {
+		return rsp, nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		log.Error(err, "decode kratos response failed", "path", path)
+		return nil, errorx.NewHTTP(err, http.StatusInternalServerError, "decode kratos response failed")
+	}
+	return rsp, nil
+}
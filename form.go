@@ -0,0 +1,263 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox
+
+import (
+	"encoding/json"
+	"net/http"
+
+	client "github.com/ory/kratos-client-go"
+
+	"github.com/w6d-io/x/errorx"
+)
+
+// Form is the form of a self-service flow, built from its ui container.
+// It is encoded as json for single page applications and can be given as is
+// to an html/template
+type Form struct {
+	// FlowID is the id of the flow
+	FlowID string `json:"flowId"`
+	// State of the flow when kratos reports it
+	State string `json:"state,omitempty"`
+	// Action is the url the form is submitted to
+	Action string `json:"action"`
+	// Method is the http method of the submission
+	Method string `json:"method"`
+	// Messages are the messages not attached to a field
+	Messages []Message `json:"messages,omitempty"`
+	// Fields are the nodes of the form in the kratos order
+	Fields []Field `json:"fields"`
+}
+
+// Field is a node of the form
+type Field struct {
+	// Name of the input, the id for the other nodes
+	Name string `json:"name"`
+	// NodeType is input, text, img, a or script
+	NodeType string `json:"nodeType"`
+	// Type of the input (text, password, hidden, submit...)
+	Type string `json:"type,omitempty"`
+	// Group is the method the node belongs to (default, password, oidc, totp...)
+	Group string `json:"group"`
+	// Label to display
+	Label string `json:"label,omitempty"`
+	// Value of the input
+	Value interface{} `json:"value,omitempty"`
+	// Required input
+	Required bool `json:"required,omitempty"`
+	// Disabled input
+	Disabled bool `json:"disabled,omitempty"`
+	// Text of the text nodes
+	Text string `json:"text,omitempty"`
+	// Href of the anchors, Src of the images and scripts
+	Href string `json:"href,omitempty"`
+	// Messages attached to the node
+	Messages []Message `json:"messages,omitempty"`
+}
+
+// Message is a message of the flow, its ID identifies it whatever the language
+type Message struct {
+	// ID of the message (ex: 4000006 for invalid credentials)
+	ID int64 `json:"id"`
+	// Type is info, error or success
+	Type string `json:"type"`
+	// Text in english
+	Text string `json:"text"`
+	// Context holds the values of the message
+	Context map[string]interface{} `json:"context,omitempty"`
+}
+
+// formBody is the flow returned by kratos, the generated client drops the node attributes
+type formBody struct {
+	ID    string `json:"id"`
+	State string `json:"state"`
+	UI    struct {
+		Action   string          `json:"action"`
+		Method   string          `json:"method"`
+		Messages []client.UiText `json:"messages"`
+		Nodes    []struct {
+			Type       string `json:"type"`
+			Group      string `json:"group"`
+			Attributes struct {
+				ID       string         `json:"id"`
+				Name     string         `json:"name"`
+				Type     string         `json:"type"`
+				NodeType string         `json:"node_type"`
+				Value    interface{}    `json:"value"`
+				Required bool           `json:"required"`
+				Disabled bool           `json:"disabled"`
+				Text     *client.UiText `json:"text"`
+				Title    *client.UiText `json:"title"`
+				Label    *client.UiText `json:"label"`
+				Href     string         `json:"href"`
+				Src      string         `json:"src"`
+			} `json:"attributes"`
+			Messages []client.UiText `json:"messages"`
+			Meta     struct {
+				Label *client.UiText `json:"label"`
+			} `json:"meta"`
+		} `json:"nodes"`
+	} `json:"ui"`
+}
+
+// ParseForm builds the form of the flow returned by kratos, any self-service flow
+// or a rejected submission
+func ParseForm(data []byte) (*Form, error) {
+	var b formBody
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, errorx.Wrap(err, "decode flow failed")
+	}
+	f := &Form{
+		FlowID:   b.ID,
+		State:    b.State,
+		Action:   b.UI.Action,
+		Method:   b.UI.Method,
+		Messages: messages(b.UI.Messages),
+		Fields:   make([]Field, 0, len(b.UI.Nodes)),
+	}
+	for _, n := range b.UI.Nodes {
+		a := n.Attributes
+		field := Field{
+			Name:     a.Name,
+			NodeType: a.NodeType,
+			Type:     a.Type,
+			Group:    n.Group,
+			Value:    a.Value,
+			Required: a.Required,
+			Disabled: a.Disabled,
+			Href:     a.Href,
+			Messages: messages(n.Messages),
+		}
+		if field.NodeType == "" {
+			field.NodeType = n.Type
+		}
+		if field.Name == "" {
+			field.Name = a.ID
+		}
+		if field.Href == "" {
+			field.Href = a.Src
+		}
+		switch {
+		case n.Meta.Label != nil:
+			field.Label = n.Meta.Label.Text
+		case a.Label != nil:
+			field.Label = a.Label.Text
+		case a.Title != nil:
+			field.Label = a.Title.Text
+		}
+		if a.Text != nil {
+			field.Text = a.Text.Text
+		}
+		f.Fields = append(f.Fields, field)
+	}
+	return f, nil
+}
+
+// Field returns the first field with the name, nil when there is none
+func (f *Form) Field(name string) *Field {
+	for i := range f.Fields {
+		if f.Fields[i].Name == name {
+			return &f.Fields[i]
+		}
+	}
+	return nil
+}
+
+// Group returns the fields of the group plus the default ones, like the csrf token
+func (f *Form) Group(group string) []Field {
+	var fields []Field
+	for _, field := range f.Fields {
+		if field.Group == group || field.Group == "default" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// Values returns the values of the inputs by name, the submit buttons excluded
+func (f *Form) Values() map[string]interface{} {
+	values := map[string]interface{}{}
+	for _, field := range f.Fields {
+		if field.NodeType != "input" || field.Type == "submit" || field.Type == "button" || field.Value == nil {
+			continue
+		}
+		values[field.Name] = field.Value
+	}
+	return values
+}
+
+// FieldMessages returns the messages by field name, only the fields with messages
+func (f *Form) FieldMessages() map[string][]Message {
+	fields := map[string][]Message{}
+	for _, field := range f.Fields {
+		if len(field.Messages) > 0 {
+			fields[field.Name] = append(fields[field.Name], field.Messages...)
+		}
+	}
+	return fields
+}
+
+// Message returns the message with the id, globally or on a field, nil when not found
+func (f *Form) Message(id int64) *Message {
+	for i := range f.Messages {
+		if f.Messages[i].ID == id {
+			return &f.Messages[i]
+		}
+	}
+	for i := range f.Fields {
+		for j := range f.Fields[i].Messages {
+			if f.Fields[i].Messages[j].ID == id {
+				return &f.Fields[i].Messages[j]
+			}
+		}
+	}
+	return nil
+}
+
+// HasErrors reports whether the form holds an error message
+func (f *Form) HasErrors() bool {
+	for _, m := range f.Messages {
+		if m.Type == "error" {
+			return true
+		}
+	}
+	for _, field := range f.Fields {
+		for _, m := range field.Messages {
+			if m.Type == "error" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WriteForm writes the form as json with the status code
+func WriteForm(w http.ResponseWriter, statusCode int, f *Form) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(f)
+}
+
+func messages(texts []client.UiText) []Message {
+	if len(texts) == 0 {
+		return nil
+	}
+	out := make([]Message, 0, len(texts))
+	for _, t := range texts {
+		out = append(out, Message{ID: t.Id, Type: t.Type, Text: t.Text, Context: t.Context})
+	}
+	return out
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox_test
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/w6d-io/kratox"
+)
+
+const loginFlow = `{
+  "id": "flow-id",
+  "type": "browser",
+  "ui": {
+    "action": "http://kratos/self-service/login?flow=flow-id",
+    "method": "POST",
+    "messages": [{"id": 4000006, "text": "The provided credentials are invalid.", "type": "error"}],
+    "nodes": [
+      {"type": "input", "group": "default", "attributes": {"name": "csrf_token", "type": "hidden", "value": "csrf", "required": true, "node_type": "input"}, "messages": [], "meta": {}},
+      {"type": "input", "group": "default", "attributes": {"name": "identifier", "type": "text", "value": "a@b.c", "required": true, "node_type": "input"}, "messages": [], "meta": {"label": {"id": 1070004, "text": "ID", "type": "info"}}},
+      {"type": "input", "group": "password", "attributes": {"name": "password", "type": "password", "required": true, "node_type": "input"}, "messages": [{"id": 4000002, "text": "Property password is missing.", "type": "error", "context": {"property": "password"}}], "meta": {"label": {"id": 1070001, "text": "Password", "type": "info"}}},
+      {"type": "input", "group": "password", "attributes": {"name": "method", "type": "submit", "value": "password", "node_type": "input"}, "messages": [], "meta": {"label": {"id": 1010001, "text": "Sign in", "type": "info"}}},
+      {"type": "a", "group": "default", "attributes": {"id": "forgot", "href": "http://kratos/recovery", "title": {"id": 1, "text": "Forgot password?", "type": "info"}, "node_type": "a"}, "messages": [], "meta": {}}
+    ]
+  }
+}`
+
+func TestParseForm(t *testing.T) {
+	f, err := kratox.ParseForm([]byte(loginFlow))
+	if err != nil {
+		t.Fatalf("ParseForm() error = %v", err)
+	}
+	if f.FlowID != "flow-id" || f.Method != "POST" || len(f.Fields) != 5 {
+		t.Fatalf("ParseForm() = %+v", f)
+	}
+
+	tests := []struct {
+		name  string
+		field string
+		want  kratox.Field
+	}{
+		{
+			name:  "input with label",
+			field: "identifier",
+			want:  kratox.Field{Name: "identifier", NodeType: "input", Type: "text", Group: "default", Label: "ID", Value: "a@b.c", Required: true},
+		},
+		{
+			name:  "input with message",
+			field: "password",
+			want: kratox.Field{Name: "password", NodeType: "input", Type: "password", Group: "password", Label: "Password", Required: true,
+				Messages: []kratox.Message{{ID: 4000002, Type: "error", Text: "Property password is missing.", Context: map[string]interface{}{"property": "password"}}}},
+		},
+		{
+			name:  "anchor",
+			field: "forgot",
+			want:  kratox.Field{Name: "forgot", NodeType: "a", Group: "default", Label: "Forgot password?", Href: "http://kratos/recovery"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := f.Field(tt.field)
+			if got == nil || !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("Field(%s) = %+v, want %+v", tt.field, got, tt.want)
+			}
+		})
+	}
+
+	if got := f.Values(); !reflect.DeepEqual(got, map[string]interface{}{"csrf_token": "csrf", "identifier": "a@b.c"}) {
+		t.Errorf("Values() = %v", got)
+	}
+	if got := f.FieldMessages(); len(got) != 1 || len(got["password"]) != 1 {
+		t.Errorf("FieldMessages() = %v", got)
+	}
+	if m := f.Message(4000006); m == nil || m.Type != "error" {
+		t.Errorf("Message(4000006) = %v", m)
+	}
+	if m := f.Message(1); m != nil {
+		t.Errorf("Message(1) = %v, want nil", m)
+	}
+	if !f.HasErrors() {
+		t.Error("HasErrors() = false")
+	}
+	if got := len(f.Group("password")); got != 5 {
+		t.Errorf("Group(password) = %d fields, want 5", got)
+	}
+	if _, err := kratox.ParseForm([]byte("{")); err == nil {
+		t.Error("ParseForm() expected an error on invalid json")
+	}
+}
+
+func TestFormRender(t *testing.T) {
+	f, err := kratox.ParseForm([]byte(loginFlow))
+	if err != nil {
+		t.Fatalf("ParseForm() error = %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	kratox.WriteForm(w, http.StatusBadRequest, f)
+	var got kratox.Form
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || w.Code != http.StatusBadRequest {
+		t.Fatalf("WriteForm() = %d %s", w.Code, w.Body.String())
+	}
+	if !reflect.DeepEqual(&got, f) {
+		t.Errorf("WriteForm() = %+v, want %+v", got, f)
+	}
+
+	tpl := template.Must(template.New("form").Parse(
+		`<form action="{{.Action}}" method="{{.Method}}">{{range .Group "password"}}{{if eq .NodeType "input"}}<input name="{{.Name}}" type="{{.Type}}">{{end}}{{end}}</form>`))
+	var b strings.Builder
+	if err := tpl.Execute(&b, f); err != nil {
+		t.Fatalf("template error = %v", err)
+	}
+	if !strings.Contains(b.String(), `<input name="password" type="password">`) {
+		t.Errorf("template = %s", b.String())
+	}
+}
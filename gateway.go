@@ -0,0 +1,119 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	client "github.com/ory/kratos-client-go"
+	"google.golang.org/grpc/metadata"
+	"k8s.io/utils/pointer"
+
+	"github.com/w6d-io/x/errorx"
+	"github.com/w6d-io/x/logx"
+)
+
+const (
+	// DefaultUserHeader is the header set by the oathkeeper header mutator with the subject
+	DefaultUserHeader = "X-User"
+	// DefaultTokenHeader is the header set by the oathkeeper id_token mutator
+	DefaultTokenHeader = "Authorization"
+)
+
+var (
+	errNoGatewayHeader = errorx.New("gateway header not found")
+)
+
+// Gateway is a session source trusting the headers forwarded by an upstream gateway
+// like ory oathkeeper, kratos is not called.
+// The headers are trusted as is unless Verifier is set, so the service must only be reachable
+// through the gateway
+type Gateway struct {
+	// UserHeader holds the identity id, X-User by default
+	UserHeader string `json:"userHeader" mapstructure:"userHeader"`
+	// TraitHeaders maps a trait name to the header holding its value (ex: email: X-User-Email)
+	TraitHeaders map[string]string `json:"traitHeaders" mapstructure:"traitHeaders"`
+	// TokenHeader holds the json web token signed by the gateway, Authorization by default.
+	// It is only read when Verifier is set
+	TokenHeader string `json:"tokenHeader" mapstructure:"tokenHeader"`
+	// Verifier checks the token signed by the gateway, the session is then built from its claims
+	Verifier *JWTVerifier `json:"verifier" mapstructure:"verifier"`
+}
+
+var _ SessionSource = &Gateway{}
+
+// GetSessionFromHTTP builds the session from the gateway headers of the http request
+func (g *Gateway) GetSessionFromHTTP(ctx context.Context, req *http.Request) (*client.Session, error) {
+	return g.session(ctx, req.Header.Get)
+}
+
+// GetSessionFromGRPCCtx builds the session from the gateway headers forwarded as gRPC metadata
+func (g *Gateway) GetSessionFromGRPCCtx(ctx context.Context) (*client.Session, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, errorx.NewHTTP(errNoMDFromCtx, http.StatusNotFound, "fail to get metadata")
+	}
+	return g.session(ctx, func(key string) string {
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	})
+}
+
+// session builds the session from the headers returned by get
+func (g *Gateway) session(ctx context.Context, get func(string) string) (*client.Session, error) {
+	log := logx.WithName(ctx, "Gateway")
+	if g.Verifier != nil {
+		header := g.TokenHeader
+		if header == "" {
+			header = DefaultTokenHeader
+		}
+		token := get(header)
+		if b := bearer(token); b != "" {
+			token = b
+		}
+		if token == "" {
+			log.Error(errNoGatewayHeader, "get gateway token failed", "header", header)
+			return nil, errorx.NewHTTP(errNoGatewayHeader, http.StatusUnauthorized, "get gateway token failed")
+		}
+		return g.Verifier.VerifySession(ctx, token)
+	}
+	header := g.UserHeader
+	if header == "" {
+		header = DefaultUserHeader
+	}
+	id := strings.TrimSpace(get(header))
+	if id == "" {
+		log.Error(errNoGatewayHeader, "get gateway user failed", "header", header)
+		return nil, errorx.NewHTTP(errNoGatewayHeader, http.StatusUnauthorized, "get gateway user failed")
+	}
+	traits := map[string]interface{}{}
+	for trait, h := range g.TraitHeaders {
+		if v := get(h); v != "" {
+			traits[trait] = v
+		}
+	}
+	return &client.Session{
+		Active: pointer.Bool(true),
+		Identity: client.Identity{
+			Id:     id,
+			Traits: traits,
+		},
+	}, nil
+}
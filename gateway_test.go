@@ -0,0 +1,129 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v3/jwt"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/w6d-io/kratox"
+)
+
+func TestGateway_GetSessionFromHTTP(t *testing.T) {
+	signer, jwks := newSigner(t, "gw")
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	d, _ := json.Marshal(jwks)
+	if err := os.WriteFile(file, d, 0600); err != nil {
+		t.Fatalf("write jwks: %v", err)
+	}
+	token := sign(t, signer, jwt.Claims{Subject: "identity-id", Issuer: "oathkeeper", Expiry: jwt.NewNumericDate(time.Now().Add(time.Minute))})
+	tests := []struct {
+		name    string
+		gateway *kratox.Gateway
+		headers map[string]string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "user header",
+			gateway: &kratox.Gateway{TraitHeaders: map[string]string{"email": "X-User-Email"}},
+			headers: map[string]string{"X-User": "identity-id", "X-User-Email": "a@b.c"},
+			want:    "identity-id",
+		},
+		{
+			name:    "custom user header",
+			gateway: &kratox.Gateway{UserHeader: "X-Subject"},
+			headers: map[string]string{"X-Subject": "identity-id"},
+			want:    "identity-id",
+		},
+		{
+			name:    "missing user header",
+			gateway: &kratox.Gateway{},
+			wantErr: true,
+		},
+		{
+			name:    "signed token",
+			gateway: &kratox.Gateway{Verifier: &kratox.JWTVerifier{Keys: &kratox.JWKS{File: file}, Issuer: "oathkeeper"}},
+			headers: map[string]string{"Authorization": "Bearer " + token, "X-User": "spoofed"},
+			want:    "identity-id",
+		},
+		{
+			name:    "forged token",
+			gateway: &kratox.Gateway{Verifier: &kratox.JWTVerifier{Keys: &kratox.JWKS{File: file}}},
+			headers: map[string]string{"Authorization": "Bearer " + token + "x"},
+			wantErr: true,
+		},
+		{
+			name:    "missing token",
+			gateway: &kratox.Gateway{Verifier: &kratox.JWTVerifier{Keys: &kratox.JWKS{File: file}}},
+			headers: map[string]string{"X-User": "spoofed"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			got, err := tt.gateway.GetSessionFromHTTP(context.Background(), req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetSessionFromHTTP() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.Identity.Id != tt.want {
+				t.Errorf("GetSessionFromHTTP() identity = %v, want %v", got.Identity.Id, tt.want)
+			}
+		})
+	}
+}
+
+func TestGateway_AuthGRPCFunc(t *testing.T) {
+	kratox.SetSessionSource(&kratox.Gateway{TraitHeaders: map[string]string{"email": "X-User-Email"}})
+	defer kratox.SetSessionSource(nil)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-user", "identity-id", "x-user-email", "a@b.c"))
+	sess, err := kratox.GetSessionFromCtx(kratox.AuthGRPCFunc(ctx))
+	if err != nil {
+		t.Fatalf("AuthGRPCFunc() did not record the session: %v", err)
+	}
+	if traits, _ := sess.Identity.Traits.(map[string]interface{}); sess.Identity.Id != "identity-id" || traits["email"] != "a@b.c" {
+		t.Errorf("AuthGRPCFunc() session = %+v", sess.Identity)
+	}
+}
+
+func TestGateway_AuthRequestFunc(t *testing.T) {
+	kratox.SetSessionSource(&kratox.Gateway{})
+	defer kratox.SetSessionSource(nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-User", "identity-id")
+	sess, err := kratox.GetSessionFromCtx(kratox.AuthRequestFunc(context.Background(), req))
+	if err != nil {
+		t.Fatalf("AuthRequestFunc() did not record the session without cookie: %v", err)
+	}
+	if sess.Identity.Id != "identity-id" {
+		t.Errorf("AuthRequestFunc() identity = %v", sess.Identity.Id)
+	}
+}
@@ -3,10 +3,13 @@ module github.com/w6d-io/kratox
 go 1.20
 
 require (
+	github.com/go-jose/go-jose/v3 v3.0.4
 	github.com/google/uuid v1.4.0
 	github.com/ory/kratos-client-go v1.0.0
 	github.com/pkg/errors v0.9.1
 	github.com/w6d-io/x v0.18.0
+	golang.org/x/crypto v0.20.0
+	golang.org/x/oauth2 v0.13.0
 	google.golang.org/grpc v1.60.1
 	k8s.io/utils v0.0.0-20230711102312-30195339c3c7
 )
@@ -19,7 +22,6 @@ replace (
 	k8s.io/component-base => k8s.io/component-base v0.26.0 // indirect
 	k8s.io/kube-openapi => k8s.io/kube-openapi v0.0.0-20221012153701-172d655c2280
 	sigs.k8s.io/controller-runtime => sigs.k8s.io/controller-runtime v0.14.0
-
 )
 
 require (
@@ -59,14 +61,14 @@ require (
 	github.com/spf13/pflag v1.0.5 // indirect
 	go.uber.org/multierr v1.10.0 // indirect
 	go.uber.org/zap v1.26.0 // indirect
-	golang.org/x/net v0.17.0 // indirect
-	golang.org/x/oauth2 v0.13.0 // indirect
-	golang.org/x/sys v0.15.0 // indirect
-	golang.org/x/term v0.15.0 // indirect
+	golang.org/x/net v0.21.0 // indirect
+	golang.org/x/sys v0.18.0 // indirect
+	golang.org/x/term v0.17.0 // indirect
 	golang.org/x/text v0.14.0 // indirect
 	golang.org/x/time v0.3.0 // indirect
 	gomodules.xyz/jsonpatch/v2 v2.3.0 // indirect
 	google.golang.org/appengine v1.6.8 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20231002182017-d307bd883b97 // indirect
 	google.golang.org/protobuf v1.31.0 // indirect
 	gopkg.in/inf.v0 v0.9.1 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
@@ -0,0 +1,154 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	client "github.com/ory/kratos-client-go"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/w6d-io/x/errorx"
+	"github.com/w6d-io/x/logx"
+)
+
+var (
+	// ErrUnsupportedHash is returned when the password hash format is not known
+	ErrUnsupportedHash = errorx.New("unsupported password hash")
+)
+
+// FirebaseScryptParams are the parameters of the firebase scrypt hashes, given by the firebase
+// console with the users export
+type FirebaseScryptParams struct {
+	// SignerKey is the base64 signer key of the project
+	SignerKey string `json:"signerKey" mapstructure:"signerKey"`
+	// SaltSeparator is the base64 salt separator of the project
+	SaltSeparator string `json:"saltSeparator" mapstructure:"saltSeparator"`
+	// Rounds of the hash
+	Rounds int `json:"rounds" mapstructure:"rounds"`
+	// MemCost is the memory cost of the hash
+	MemCost int `json:"memCost" mapstructure:"memCost"`
+}
+
+// HashFromDjango converts a django pbkdf2_sha256 hash (pbkdf2_sha256$iterations$salt$hash)
+// into the kratos pbkdf2 format
+func HashFromDjango(hash string) (string, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2_sha256" {
+		return "", fmt.Errorf("%w: not a django pbkdf2_sha256 hash", ErrUnsupportedHash)
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return "", fmt.Errorf("%w: invalid iterations %q", ErrUnsupportedHash, parts[1])
+	}
+	key, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid hash: %v", ErrUnsupportedHash, err)
+	}
+	// django uses the salt as is, kratos decodes it from base64
+	return fmt.Sprintf("$pbkdf2-sha256$i=%d,l=%d$%s$%s",
+		iterations,
+		len(key),
+		base64.RawStdEncoding.EncodeToString([]byte(parts[2])),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// HashFromBcrypt checks a bcrypt hash, like the ones of php password_hash ($2y$), kratos reads it as is
+func HashFromBcrypt(hash string) (string, error) {
+	if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+		return "", fmt.Errorf("%w: not a bcrypt hash: %v", ErrUnsupportedHash, err)
+	}
+	return hash, nil
+}
+
+// HashFromFirebaseScrypt converts a firebase scrypt hash and its base64 salt into the kratos firescrypt format
+func HashFromFirebaseScrypt(hash, salt string, params FirebaseScryptParams) (string, error) {
+	for name, v := range map[string]string{"hash": hash, "salt": salt, "signer key": params.SignerKey, "salt separator": params.SaltSeparator} {
+		if _, err := base64.StdEncoding.DecodeString(v); err != nil || v == "" {
+			return "", fmt.Errorf("%w: invalid firebase %s", ErrUnsupportedHash, name)
+		}
+	}
+	if params.Rounds <= 0 || params.MemCost <= 0 {
+		return "", fmt.Errorf("%w: invalid firebase rounds or memory cost", ErrUnsupportedHash)
+	}
+	return fmt.Sprintf("$firescrypt$ln=%d,r=%d,p=1$%s$%s$%s$%s",
+		params.MemCost, params.Rounds, salt, hash, params.SaltSeparator, params.SignerKey), nil
+}
+
+// HashFromArgon2id checks an argon2id hash in the phc string format
+// ($argon2id$v=19$m=65536,t=3,p=4$salt$hash), kratos reads it as is
+func HashFromArgon2id(hash string) (string, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" {
+		return "", fmt.Errorf("%w: not an argon2id phc string", ErrUnsupportedHash)
+	}
+	var version, memory, passes, threads int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return "", fmt.Errorf("%w: invalid argon2id version", ErrUnsupportedHash)
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &passes, &threads); err != nil {
+		return "", fmt.Errorf("%w: invalid argon2id parameters", ErrUnsupportedHash)
+	}
+	for _, p := range parts[4:] {
+		if _, err := base64.RawStdEncoding.DecodeString(p); err != nil {
+			return "", fmt.Errorf("%w: invalid argon2id salt or hash", ErrUnsupportedHash)
+		}
+	}
+	return hash, nil
+}
+
+// ImportHash detects the format of the hash among django pbkdf2_sha256, bcrypt and argon2id
+// and converts it into the kratos format, firebase hashes need HashFromFirebaseScrypt
+func ImportHash(hash string) (string, error) {
+	switch {
+	case strings.HasPrefix(hash, "pbkdf2_sha256$"):
+		return HashFromDjango(hash)
+	case strings.HasPrefix(hash, "$2"):
+		return HashFromBcrypt(hash)
+	case strings.HasPrefix(hash, "$argon2id$"):
+		return HashFromArgon2id(hash)
+	}
+	return "", ErrUnsupportedHash
+}
+
+// CreateIdentityWithHashedPassword creates the identity with the password credential holding the hash,
+// in the kratos format, the user logs in with the password of the legacy system
+// if kratos is unreachable or an other issues, return nil with statusCode of the call and error-go
+func (a auth) CreateIdentityWithHashedPassword(ctx context.Context, schemaId string, trait map[string]interface{}, hashedPassword string) (*client.Identity, error) {
+	log := logx.WithName(ctx, "CreateIdentityWithHashedPassword")
+	api, err := a.adminAPI()
+	if err != nil {
+		return nil, err
+	}
+	config := client.NewIdentityWithCredentialsPasswordConfig()
+	config.SetHashedPassword(hashedPassword)
+	body := client.NewCreateIdentityBody(schemaId, trait)
+	body.Credentials = &client.IdentityWithCredentials{
+		Password: &client.IdentityWithCredentialsPassword{Config: config},
+	}
+	createdIdentity, r, err := api.IdentityApi.CreateIdentity(ctx).CreateIdentityBody(*body).Execute()
+	if err != nil {
+		log.Error(err, "calling fail", "name", "CreateIdentity", "response", r)
+		return nil, errorx.NewHTTP(err, statusOf(r, err), "fail to call kratos")
+	}
+	log.V(1).Info("create identity", "id", createdIdentity.Id)
+	return createdIdentity, nil
+}
@@ -0,0 +1,224 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/w6d-io/kratox"
+)
+
+// firebaseParams are the parameters of the firebase scrypt sample
+var firebaseParams = kratox.FirebaseScryptParams{
+	SignerKey:     "jxspr8Ki0RYycVU8zykbdLGjFQ3McFUH0uiiTvC8pVMXAn210wjLNmdZJzxUECKbm0QsEmYUSDzZvpjeJ9WmXA==",
+	SaltSeparator: "Bw==",
+	Rounds:        8,
+	MemCost:       14,
+}
+
+// verify checks the password against the hash in the kratos format, like kratos does
+func verify(t *testing.T, hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	b64 := func(s string, enc *base64.Encoding) []byte {
+		d, err := enc.DecodeString(s)
+		if err != nil {
+			t.Fatalf("decode %s: %v", s, err)
+		}
+		return d
+	}
+	switch parts[1] {
+	case "pbkdf2-sha256":
+		var i, l int
+		if _, err := fmt.Sscanf(parts[2], "i=%d,l=%d", &i, &l); err != nil {
+			t.Fatal(err)
+		}
+		salt, key := b64(parts[3], base64.RawStdEncoding), b64(parts[4], base64.RawStdEncoding)
+		return bytes.Equal(pbkdf2.Key([]byte(password), salt, i, l, sha256.New), key)
+	case "argon2id":
+		var m, i, p uint32
+		if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &m, &i, &p); err != nil {
+			t.Fatal(err)
+		}
+		salt, key := b64(parts[4], base64.RawStdEncoding), b64(parts[5], base64.RawStdEncoding)
+		return bytes.Equal(argon2.IDKey([]byte(password), salt, i, m, uint8(p), uint32(len(key))), key)
+	case "firescrypt":
+		var ln, r, p int
+		if _, err := fmt.Sscanf(parts[2], "ln=%d,r=%d,p=%d", &ln, &r, &p); err != nil {
+			t.Fatal(err)
+		}
+		salt, key := b64(parts[3], base64.StdEncoding), b64(parts[4], base64.StdEncoding)
+		sep, signer := b64(parts[5], base64.StdEncoding), b64(parts[6], base64.StdEncoding)
+		derived, err := scrypt.Key([]byte(password), append(salt, sep...), 1<<ln, r, p, 32)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block, err := aes.NewCipher(derived)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := make([]byte, len(signer))
+		cipher.NewCTR(block, make([]byte, aes.BlockSize)).XORKeyStream(out, signer)
+		return bytes.Equal(out, key)
+	default:
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	}
+}
+
+func TestImportHash(t *testing.T) {
+	tests := []struct {
+		name     string
+		convert  func() (string, error)
+		password string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name: "django pbkdf2_sha256",
+			convert: func() (string, error) {
+				return kratox.ImportHash("pbkdf2_sha256$600000$seasalt42$JyZCN5d9UuHdUV4P8rHIitcsP2aOuQf7CM52HraLS0g=")
+			},
+			password: "correct horse",
+			want:     "$pbkdf2-sha256$i=600000,l=32$c2Vhc2FsdDQy$JyZCN5d9UuHdUV4P8rHIitcsP2aOuQf7CM52HraLS0g",
+		},
+		{
+			name: "php bcrypt",
+			convert: func() (string, error) {
+				return kratox.ImportHash("$2y$10$.vGA1O9wmRjrwAVXD98HNOgsNpDczlqm3Jq7KnEd1rVAGv3Fykk1a")
+			},
+			password: "rasmuslerdorf",
+			want:     "$2y$10$.vGA1O9wmRjrwAVXD98HNOgsNpDczlqm3Jq7KnEd1rVAGv3Fykk1a",
+		},
+		{
+			name: "firebase scrypt",
+			convert: func() (string, error) {
+				return kratox.HashFromFirebaseScrypt(
+					"lSrfV15cpx95/sZS2W9c9Kp6i/LVgQNDNC/qzrCnh1SAyZvqmZqAjTdn3aoItz+VHjoZilo78198JAdRuid5lQ==",
+					"42xEC+ixf3L2lw==", firebaseParams)
+			},
+			password: "user1password",
+			want: "$firescrypt$ln=14,r=8,p=1$42xEC+ixf3L2lw==$lSrfV15cpx95/sZS2W9c9Kp6i/LVgQNDNC/qzrCnh1SAyZvqmZqAjTdn3aoItz+VHjoZilo78198JAdRuid5lQ==$Bw==$" +
+				"jxspr8Ki0RYycVU8zykbdLGjFQ3McFUH0uiiTvC8pVMXAn210wjLNmdZJzxUECKbm0QsEmYUSDzZvpjeJ9WmXA==",
+		},
+		{
+			name: "argon2id",
+			convert: func() (string, error) {
+				return kratox.ImportHash("$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc")
+			},
+			password: "password",
+			want:     "$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc",
+		},
+		{
+			name:    "django with invalid iterations",
+			convert: func() (string, error) { return kratox.HashFromDjango("pbkdf2_sha256$x$salt$aGFzaA==") },
+			wantErr: true,
+		},
+		{
+			name:    "truncated bcrypt",
+			convert: func() (string, error) { return kratox.HashFromBcrypt("$2y$10$short") },
+			wantErr: true,
+		},
+		{
+			name: "argon2i",
+			convert: func() (string, error) {
+				return kratox.HashFromArgon2id("$argon2i$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$aGFzaA")
+			},
+			wantErr: true,
+		},
+		{
+			name: "firebase without signer key",
+			convert: func() (string, error) {
+				return kratox.HashFromFirebaseScrypt("aGFzaA==", "c2FsdA==", kratox.FirebaseScryptParams{SaltSeparator: "Bw==", Rounds: 8, MemCost: 14})
+			},
+			wantErr: true,
+		},
+		{
+			name:    "md5",
+			convert: func() (string, error) { return kratox.ImportHash("5f4dcc3b5aa765d61d8327deb882cf99") },
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.convert()
+			if tt.wantErr {
+				if !errors.Is(err, kratox.ErrUnsupportedHash) {
+					t.Errorf("error = %v, want %v", err, kratox.ErrUnsupportedHash)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("got %s, %v, want %s", got, err, tt.want)
+			}
+			if !verify(t, got, tt.password) {
+				t.Errorf("password does not match the converted hash")
+			}
+			if verify(t, got, "wrong"+tt.password) {
+				t.Errorf("wrong password matches the converted hash")
+			}
+		})
+	}
+}
+
+func TestCreateIdentityWithHashedPassword(t *testing.T) {
+	var body struct {
+		Credentials struct {
+			Password struct {
+				Config struct {
+					HashedPassword string `json:"hashed_password"`
+				} `json:"config"`
+			} `json:"password"`
+		} `json:"credentials"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"id": "identity-id", "schema_id": "default", "schema_url": "", "traits": map[string]string{}})
+	}))
+	defer srv.Close()
+	kratox.SetAddress(srv.URL, srv.URL)
+
+	hash, err := kratox.ImportHash("pbkdf2_sha256$600000$seasalt42$JyZCN5d9UuHdUV4P8rHIitcsP2aOuQf7CM52HraLS0g=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	i, err := kratox.Kratox.CreateIdentityWithHashedPassword(context.Background(), "default", map[string]interface{}{"email": "a@b.c"}, hash)
+	if err != nil || i.Id != "identity-id" {
+		t.Fatalf("CreateIdentityWithHashedPassword() = %v, %v", i, err)
+	}
+	if body.Credentials.Password.Config.HashedPassword != hash {
+		t.Errorf("hashed password = %s, want %s", body.Credentials.Password.Config.HashedPassword, hash)
+	}
+}
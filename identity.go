@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"net/url"
+	"strconv"
 
 	client "github.com/ory/kratos-client-go"
 
@@ -11,31 +13,25 @@ import (
 	"github.com/w6d-io/x/logx"
 )
 
+const (
+	// identitiesPerPage is the page size of ListAllIdentities
+	identitiesPerPage = 250
+)
+
 // DeleteIdentity is used to delete the identity who correspond to the user id on kratos service
 // if kratos is unreachable or an other issues, return nil session with statusCode of the call and error-go
 func (a auth) DeleteIdentity(ctx context.Context, id string) error {
 	log := logx.WithName(ctx, "DeleteIdentity")
 
-	cfg := client.NewConfiguration()
-
-	u, err := a.getKratosAdminAddress()
+	api, err := a.adminAPI()
 	if err != nil {
-		return errorx.NewHTTP(err, http.StatusInternalServerError, "fail to get kratos address")
-	}
-	cfg.Scheme = u.Scheme
-	cfg.Host = u.Host
-	cfg.Servers = []client.ServerConfiguration{
-		{
-			URL: u.String(),
-		},
+		return err
 	}
 
-	api := client.NewAPIClient(cfg)
-
 	r, err := api.IdentityApi.DeleteIdentity(ctx, id).Execute()
 	if err != nil {
 		log.Error(err, "calling fail", "name", "DeleteIdentity", "response", r)
-		return errorx.NewHTTP(err, r.StatusCode, "fail to call kratos")
+		return errorx.NewHTTP(err, statusOf(r, err), "fail to call kratos")
 	}
 
 	log.V(1).Info("identity deleted", "id", id)
@@ -48,22 +44,11 @@ func (a auth) DeleteIdentity(ctx context.Context, id string) error {
 func (a auth) UpdateIdentity(ctx context.Context, id string, schemaId string, trait map[string]interface{}) (*client.Identity, error) {
 	log := logx.WithName(ctx, "UpdateIdentity")
 
-	cfg := client.NewConfiguration()
-
-	u, err := a.getKratosAdminAddress()
+	api, err := a.adminAPI()
 	if err != nil {
-		return nil, errorx.NewHTTP(err, http.StatusInternalServerError, "fail to get kratos address")
-	}
-	cfg.Scheme = u.Scheme
-	cfg.Host = u.Host
-	cfg.Servers = []client.ServerConfiguration{
-		{
-			URL: u.String(),
-		},
+		return nil, err
 	}
 
-	api := client.NewAPIClient(cfg)
-
 	adminUpdateIdentityBody := *client.NewUpdateIdentityBody(
 		schemaId,
 		"active",
@@ -73,7 +58,7 @@ func (a auth) UpdateIdentity(ctx context.Context, id string, schemaId string, tr
 	updateIdentity, r, err := api.IdentityApi.UpdateIdentity(ctx, id).UpdateIdentityBody(adminUpdateIdentityBody).Execute()
 	if err != nil {
 		log.Error(err, "calling fail", "name", "UpdateIdentity", "response", r)
-		return nil, errorx.NewHTTP(err, r.StatusCode, "fail to call kratos")
+		return nil, errorx.NewHTTP(err, statusOf(r, err), "fail to call kratos")
 	}
 	// response from `updateIdentity`: Identity
 	log.V(1).Info("identity updated", "id", updateIdentity.Id)
@@ -86,22 +71,11 @@ func (a auth) UpdateIdentity(ctx context.Context, id string, schemaId string, tr
 func (a auth) CreateIdentity(ctx context.Context, schemaId string, trait map[string]interface{}) (*client.Identity, error) {
 	log := logx.WithName(ctx, "CreateIdentity")
 
-	cfg := client.NewConfiguration()
-
-	u, err := a.getKratosAdminAddress()
+	api, err := a.adminAPI()
 	if err != nil {
-		return nil, errorx.NewHTTP(err, http.StatusInternalServerError, "fail to get kratos address")
-	}
-	cfg.Scheme = u.Scheme
-	cfg.Host = u.Host
-	cfg.Servers = []client.ServerConfiguration{
-		{
-			URL: u.String(),
-		},
+		return nil, err
 	}
 
-	api := client.NewAPIClient(cfg)
-
 	adminCreateIdentityBody := *client.NewCreateIdentityBody(
 		schemaId,
 		trait,
@@ -110,7 +84,7 @@ func (a auth) CreateIdentity(ctx context.Context, schemaId string, trait map[str
 	createdIdentity, r, err := api.IdentityApi.CreateIdentity(ctx).CreateIdentityBody(adminCreateIdentityBody).Execute()
 	if err != nil {
 		log.Error(err, "calling fail", "name", "CreateIdentity", "response", r)
-		return nil, errorx.NewHTTP(err, r.StatusCode, "fail to call kratos")
+		return nil, errorx.NewHTTP(err, statusOf(r, err), "fail to call kratos")
 	}
 	// response from `AdminCreateIdentity`: Identity
 	log.V(1).Info("create identity", "id", createdIdentity.Id)
@@ -124,26 +98,15 @@ func (a auth) CreateIdentity(ctx context.Context, schemaId string, trait map[str
 func (a auth) GetIdentity(ctx context.Context, id string) (*client.Identity, error) {
 	log := logx.WithName(ctx, "GetIdentity")
 
-	cfg := client.NewConfiguration()
-
-	u, err := a.getKratosAdminAddress()
+	api, err := a.adminAPI()
 	if err != nil {
-		return nil, errorx.NewHTTP(err, http.StatusInternalServerError, "fail to get kratos address")
-	}
-	cfg.Scheme = u.Scheme
-	cfg.Host = u.Host
-	cfg.Servers = []client.ServerConfiguration{
-		{
-			URL: u.String(),
-		},
+		return nil, err
 	}
 
-	api := client.NewAPIClient(cfg)
-
 	getIdentity, r, err := api.IdentityApi.GetIdentity(ctx, id).Execute()
 	if err != nil {
 		log.Error(err, "calling fail", "name", "GetIdentity", "response", r)
-		return nil, errorx.NewHTTP(err, r.StatusCode, "fail to call kratos")
+		return nil, errorx.NewHTTP(err, statusOf(r, err), "fail to call kratos")
 	}
 
 	log.V(2).Info("get identity", "id", id)
@@ -153,32 +116,53 @@ func (a auth) GetIdentity(ctx context.Context, id string) (*client.Identity, err
 // GetIdentityWithCredentials is used to get the identity who correspond to the user id on kratos service
 // if kratos is unreachable or an other issues, return nil session with statusCode of the call and error-go
 func (a auth) GetIdentityWithCredentials(ctx context.Context, id string) (*client.Identity, error) {
-	log := logx.WithName(ctx, "GetIdentityWithCredentials")
-	includeCredential := []string{"oidc"}
-	cfg := client.NewConfiguration()
+	i, _, err := a.getIdentityWithCredentials(ctx, id, string(client.IDENTITYCREDENTIALSTYPE_OIDC))
+	return i, err
+}
 
-	u, err := a.getKratosAdminAddress()
-	if err != nil {
-		return nil, errorx.NewHTTP(err, http.StatusInternalServerError, "fail to get kratos address")
+// getIdentityWithCredentials gets the identity with the config of the credential types, and the raw
+// credentials by type. The identity is decoded apart from the credentials as the generated client
+// rejects the types it does not know (code, passkey), its Credentials only hold the known ones
+func (a auth) getIdentityWithCredentials(ctx context.Context, id string, includeCredential ...string) (*client.Identity, map[string]json.RawMessage, error) {
+	log := logx.WithName(ctx, "GetIdentityWithCredentials")
+	raw := map[string]json.RawMessage{}
+	query := url.Values{"include_credential": includeCredential}
+	if err := a.adminCall(ctx, http.MethodGet, "/admin/identities/"+url.PathEscape(id), query, nil, &raw); err != nil {
+		log.Error(err, "calling fail", "name", "GetIdentity", "id", id)
+		return nil, nil, err
 	}
-	cfg.Scheme = u.Scheme
-	cfg.Host = u.Host
-	cfg.Servers = []client.ServerConfiguration{
-		{
-			URL: u.String(),
-		},
+	credentials := map[string]json.RawMessage{}
+	if c, ok := raw["credentials"]; ok {
+		if err := json.Unmarshal(c, &credentials); err != nil {
+			log.Error(err, "decode credentials failed", "id", id)
+			return nil, nil, errorx.NewHTTP(err, http.StatusInternalServerError, "decode credentials failed")
+		}
+		delete(raw, "credentials")
 	}
-
-	api := client.NewAPIClient(cfg)
-
-	getIdentity, r, err := api.IdentityApi.GetIdentity(ctx, id).IncludeCredential(includeCredential).Execute()
+	d, err := json.Marshal(raw)
 	if err != nil {
-		log.Error(err, "calling fail", "name", "GetIdentity", "response", r)
-		return nil, errorx.NewHTTP(err, r.StatusCode, "fail to call kratos")
+		return nil, nil, errorx.NewHTTP(err, http.StatusInternalServerError, "encode identity failed")
+	}
+	getIdentity := &client.Identity{}
+	if err := json.Unmarshal(d, getIdentity); err != nil {
+		log.Error(err, "decode identity failed", "id", id)
+		return nil, nil, errorx.NewHTTP(err, http.StatusInternalServerError, "decode identity failed")
+	}
+	if len(credentials) > 0 {
+		known := map[string]client.IdentityCredentials{}
+		for t, c := range credentials {
+			var cred client.IdentityCredentials
+			if err := json.Unmarshal(c, &cred); err != nil {
+				log.V(2).Info("credential type unknown by the client", "type", t)
+				continue
+			}
+			known[t] = cred
+		}
+		getIdentity.Credentials = &known
 	}
 
 	log.V(2).Info("get identity", "id", id)
-	return getIdentity, err
+	return getIdentity, credentials, nil
 }
 
 // GetIdentityFromCtx gets the session from context and retrieve the identity ID
@@ -191,37 +175,59 @@ func (a auth) GetIdentityFromCtx(ctx context.Context) (*client.Identity, error)
 	return a.GetIdentity(ctx, sess.Identity.Id)
 }
 
-// GetToken returns all tokens linked with the provider
+// GetToken returns the tokens of the first account linked with the provider.
+// It returns ErrProviderNotFound with the 404 status when the provider is not linked
 func (a auth) GetToken(ctx context.Context, providerID string) (*Provider, error) {
-	log := logx.WithName(ctx, "GetTokenByHttp")
+	accounts, err := a.GetProviderAccounts(ctx, providerID)
+	if err != nil {
+		return nil, err
+	}
+	return &accounts[0], nil
+}
+
+// GetProviderAccounts returns the tokens of all the accounts linked with the provider,
+// one by subject. It returns ErrProviderNotFound with the 404 status when the provider is not linked
+func (a auth) GetProviderAccounts(ctx context.Context, providerID string) ([]Provider, error) {
+	log := logx.WithName(ctx, "GetProviderAccounts")
 	providers, err := a.GetTokens(ctx)
 	if err != nil {
 		log.Error(err, "get all tokens failed")
 		return nil, err
 	}
+	var accounts []Provider
 	for _, provider := range providers {
 		if provider.Provider == providerID {
-			return &provider, nil
+			accounts = append(accounts, provider)
 		}
 	}
-	logx.WithName(ctx, "GetTokenByHttp").Error(nil, "provider not match")
-	return &Provider{}, nil
-
+	if len(accounts) == 0 {
+		log.Error(ErrProviderNotFound, "provider not match", "provider", providerID)
+		return nil, errorx.NewHTTP(ErrProviderNotFound, http.StatusNotFound, "provider not linked")
+	}
+	return accounts, nil
 }
 
 // GetTokens returns all tokens
 func (a auth) GetTokens(ctx context.Context) ([]Provider, error) {
-	log := logx.WithName(ctx, "GetTokensByHttp")
 	sess, err := GetSessionFromCtx(ctx)
 	if err != nil {
 		return nil, err
 	}
-	i, err := a.GetIdentityWithCredentials(ctx, sess.Identity.Id)
+	return a.GetIdentityProviders(ctx, sess.Identity.Id)
+}
+
+// GetIdentityProviders returns the tokens of all the accounts linked with the identity
+func (a auth) GetIdentityProviders(ctx context.Context, id string) ([]Provider, error) {
+	log := logx.WithName(ctx, "GetIdentityProviders")
+	i, err := a.GetIdentityWithCredentials(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
 	var providers []Provider
+	if i.Credentials == nil {
+		return providers, nil
+	}
 	creds := *i.Credentials
 	if cred, ok := creds[string(client.IDENTITYCREDENTIALSTYPE_OIDC)]; ok {
 		if provider, ok := cred.Config["providers"]; ok {
@@ -245,22 +251,10 @@ func (a auth) GetTokens(ctx context.Context) ([]Provider, error) {
 // PatchIdentity record some field of identity
 func (a auth) PatchIdentity(ctx context.Context, id string, jsonPatch []client.JsonPatch) (*client.Identity, error) {
 	log := logx.WithName(ctx, "PatchIdentity")
-	cfg := client.NewConfiguration()
-
-	u, err := a.getKratosAdminAddress()
+	api, err := a.adminAPI()
 	if err != nil {
-		log.Error(err, "fail to get admin kratos address")
-		return nil, errorx.NewHTTP(err, http.StatusInternalServerError, "fail to get kratos address")
-	}
-	cfg.Scheme = u.Scheme
-	cfg.Host = u.Host
-	cfg.Servers = []client.ServerConfiguration{
-		{
-			URL: u.String(),
-		},
+		return nil, err
 	}
-
-	api := client.NewAPIClient(cfg)
 	r := api.IdentityApi.PatchIdentity(ctx, id).JsonPatch(jsonPatch)
 
 	i, _, err := r.Execute()
@@ -270,3 +264,30 @@ func (a auth) PatchIdentity(ctx context.Context, id string, jsonPatch []client.J
 	}
 	return i, nil
 }
+
+// ListAllIdentities lists the identities of all the pages, following the next links of the kratos
+// pagination. Kratos v1.1 and later link the pages by token, the keyset pagination does not skip
+// nor repeat identities when some are created or deleted meanwhile. The older versions link them
+// by number
+// if kratos is unreachable or an other issues, return nil with statusCode of the call and error-go
+func (a auth) ListAllIdentities(ctx context.Context) ([]client.Identity, error) {
+	log := logx.WithName(ctx, "ListAllIdentities")
+	var identities []client.Identity
+	size := strconv.Itoa(identitiesPerPage)
+	query := url.Values{"page_size": []string{size}, "per_page": []string{size}}
+	for {
+		var page []client.Identity
+		next, err := a.adminPage(ctx, "/admin/identities", query, &page)
+		if err != nil {
+			log.Error(err, "list identities failed", "count", len(identities))
+			return nil, err
+		}
+		identities = append(identities, page...)
+		if len(page) == 0 || (next.Get("page_token") == "" && next.Get("page") == "") {
+			break
+		}
+		query = next
+	}
+	log.V(2).Info("list identities", "count", len(identities))
+	return identities, nil
+}
@@ -0,0 +1,339 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+	client "github.com/ory/kratos-client-go"
+	"google.golang.org/grpc/metadata"
+	"k8s.io/utils/pointer"
+
+	"github.com/w6d-io/x/errorx"
+	"github.com/w6d-io/x/logx"
+)
+
+const (
+	// defaultJWKSRefresh is how long a key set fetched from an url is kept
+	defaultJWKSRefresh = 5 * time.Minute
+	// jwksMinRefresh avoids hammering the key set url on unknown key ids
+	jwksMinRefresh = 10 * time.Second
+)
+
+var (
+	errNoJWKS        = errorx.New("no json web key set configured")
+	errNoBearerToken = errorx.New("bearer token not found")
+	errNoExpiry      = errorx.New("token has no expiry")
+)
+
+// JWKS is the json web key set used to verify the signature of json web tokens
+// it is read from File or fetched from URL
+type JWKS struct {
+	// URL where the key set is fetched from
+	URL string `json:"url" mapstructure:"url"`
+	// File holding the key set
+	File string `json:"file" mapstructure:"file"`
+	// RefreshInterval is how long the keys fetched from URL are kept, 5 minutes by default
+	RefreshInterval time.Duration `json:"refreshInterval" mapstructure:"refreshInterval"`
+	// HTTPClient is the client used to fetch the keys, http.DefaultClient when nil
+	HTTPClient *http.Client `json:"-" mapstructure:"-"`
+
+	mu        sync.Mutex
+	keys      *jose.JSONWebKeySet
+	fetchedAt time.Time
+}
+
+// JWTVerifier verifies json web tokens, like the sessions tokenized by kratos
+type JWTVerifier struct {
+	// Keys is the key set used to check the signature
+	Keys *JWKS `json:"keys" mapstructure:"keys"`
+	// Issuer expected in the iss claim, not checked when empty
+	Issuer string `json:"issuer" mapstructure:"issuer"`
+	// Audience holds the accepted audiences, the aud claim must contain one of them when set
+	Audience []string `json:"audience" mapstructure:"audience"`
+	// Leeway is the clock skew tolerated on the exp, nbf and iat claims
+	Leeway time.Duration `json:"leeway" mapstructure:"leeway"`
+}
+
+// Verify checks the signature, the issuer, the audience and the validity period of the token
+// and returns all its claims. A token without exp claim is rejected
+func (v *JWTVerifier) Verify(ctx context.Context, token string) (map[string]interface{}, error) {
+	log := logx.WithName(ctx, "JWTVerify")
+	tok, err := jwt.ParseSigned(token)
+	if err != nil {
+		log.Error(err, "parse token failed")
+		return nil, errorx.NewHTTP(err, http.StatusUnauthorized, "invalid token")
+	}
+	if v.Keys == nil {
+		return nil, errorx.NewHTTP(errNoJWKS, http.StatusInternalServerError, "no key to verify token")
+	}
+	var std jwt.Claims
+	claims := map[string]interface{}{}
+	if err := v.Keys.verify(ctx, tok, &std, &claims); err != nil {
+		return nil, err
+	}
+	if std.Expiry == nil {
+		log.Error(errNoExpiry, "validate token claims failed")
+		return nil, errorx.NewHTTP(errNoExpiry, http.StatusUnauthorized, "invalid token claims")
+	}
+	if err := std.ValidateWithLeeway(jwt.Expected{Issuer: v.Issuer, Time: time.Now()}, v.Leeway); err != nil {
+		log.Error(err, "validate token claims failed")
+		return nil, errorx.NewHTTP(err, http.StatusUnauthorized, "invalid token claims")
+	}
+	if len(v.Audience) > 0 && !containsAny(std.Audience, v.Audience) {
+		log.Error(jwt.ErrInvalidAudience, "validate token audience failed", "aud", std.Audience)
+		return nil, errorx.NewHTTP(jwt.ErrInvalidAudience, http.StatusUnauthorized, "invalid token audience")
+	}
+	return claims, nil
+}
+
+// VerifySession verifies a session tokenized by kratos and maps its claims into a session.
+// The sub claim is the identity id and sid the session id. When the claims mapper of the
+// tokenizer template sets a session claim, it is decoded as the whole session
+func (v *JWTVerifier) VerifySession(ctx context.Context, token string) (*client.Session, error) {
+	claims, err := v.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	sess, err := sessionFromClaims(claims)
+	if err != nil {
+		logx.WithName(ctx, "VerifySession").Error(err, "map claims into session failed")
+		return nil, errorx.NewHTTP(err, http.StatusUnauthorized, "invalid session claims")
+	}
+	return sess, nil
+}
+
+// GetSessionFromHTTP verifies the tokenized session from the Authorization bearer header
+func (v *JWTVerifier) GetSessionFromHTTP(ctx context.Context, req *http.Request) (*client.Session, error) {
+	token := bearer(req.Header.Get("Authorization"))
+	if token == "" {
+		return nil, errorx.NewHTTP(errNoBearerToken, http.StatusUnauthorized, "get bearer token failed")
+	}
+	return v.VerifySession(ctx, token)
+}
+
+// GetSessionFromGRPCCtx verifies the tokenized session from the authorization bearer metadata
+func (v *JWTVerifier) GetSessionFromGRPCCtx(ctx context.Context) (*client.Session, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, errorx.NewHTTP(errNoMDFromCtx, http.StatusNotFound, "fail to get metadata")
+	}
+	var token string
+	if values := md.Get("authorization"); len(values) > 0 {
+		token = bearer(values[0])
+	}
+	if token == "" {
+		return nil, errorx.NewHTTP(errNoBearerToken, http.StatusUnauthorized, "get bearer token failed")
+	}
+	return v.VerifySession(ctx, token)
+}
+
+// AuthRequestFunc verifies the tokenized session from the http request and records it into the context
+func (v *JWTVerifier) AuthRequestFunc(ctx context.Context, r *http.Request) context.Context {
+	session, err := v.GetSessionFromHTTP(ctx, r)
+	if err != nil {
+		logx.WithName(ctx, "OptionAuthn").Info("verify tokenized session failed")
+		return ctx
+	}
+	return SetSessionInCtx(ctx, session)
+}
+
+// AuthGRPCFunc verifies the tokenized session from the gRPC metadata and records it into the context
+func (v *JWTVerifier) AuthGRPCFunc(ctx context.Context) context.Context {
+	session, err := v.GetSessionFromGRPCCtx(ctx)
+	if err != nil {
+		logx.WithName(ctx, "OptionAuthn").Info("verify tokenized session failed")
+		return ctx
+	}
+	return SetSessionInCtx(ctx, session)
+}
+
+// verify checks the signature of the token against the keys and decodes its claims into out
+func (j *JWKS) verify(ctx context.Context, tok *jwt.JSONWebToken, out ...interface{}) error {
+	kid := ""
+	if len(tok.Headers) > 0 {
+		kid = tok.Headers[0].KeyID
+	}
+	keys, err := j.lookup(ctx, kid)
+	if err != nil {
+		return err
+	}
+	err = fmt.Errorf("no key matching %q", kid)
+	for _, key := range keys {
+		if err = tok.Claims(key.Key, out...); err == nil {
+			return nil
+		}
+	}
+	logx.WithName(ctx, "JWKS").Error(err, "verify token signature failed", "kid", kid)
+	return errorx.NewHTTP(err, http.StatusUnauthorized, "invalid token signature")
+}
+
+// lookup returns the keys matching the key id, all the keys when kid is empty.
+// The key set is fetched again from the url when it is stale or when the key id is unknown
+func (j *JWKS) lookup(ctx context.Context, kid string) ([]jose.JSONWebKey, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	refresh := j.RefreshInterval
+	if refresh <= 0 {
+		refresh = defaultJWKSRefresh
+	}
+	if j.keys == nil || (j.URL != "" && time.Since(j.fetchedAt) > refresh) {
+		if err := j.load(ctx); err != nil {
+			return nil, err
+		}
+	}
+	keys := j.match(kid)
+	if len(keys) == 0 && j.URL != "" && time.Since(j.fetchedAt) > jwksMinRefresh {
+		if err := j.load(ctx); err != nil {
+			return nil, err
+		}
+		keys = j.match(kid)
+	}
+	return keys, nil
+}
+
+func (j *JWKS) match(kid string) []jose.JSONWebKey {
+	if kid == "" {
+		return j.keys.Keys
+	}
+	return j.keys.Key(kid)
+}
+
+// load reads the key set from the file or the url
+func (j *JWKS) load(ctx context.Context) error {
+	log := logx.WithName(ctx, "JWKS")
+	var data []byte
+	switch {
+	case j.File != "":
+		d, err := os.ReadFile(j.File)
+		if err != nil {
+			log.Error(err, "read key set failed", "file", j.File)
+			return errorx.NewHTTP(err, http.StatusInternalServerError, "read key set failed")
+		}
+		data = d
+	case j.URL != "":
+		d, err := j.fetch(ctx)
+		if err != nil {
+			log.Error(err, "fetch key set failed", "url", j.URL)
+			return errorx.NewHTTP(err, http.StatusInternalServerError, "fetch key set failed")
+		}
+		data = d
+	default:
+		return errorx.NewHTTP(errNoJWKS, http.StatusInternalServerError, "no key set source")
+	}
+	keys := &jose.JSONWebKeySet{}
+	if err := json.Unmarshal(data, keys); err != nil {
+		log.Error(err, "decode key set failed")
+		return errorx.NewHTTP(err, http.StatusInternalServerError, "decode key set failed")
+	}
+	j.keys = keys
+	j.fetchedAt = time.Now()
+	return nil
+}
+
+func (j *JWKS) fetch(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	c := j.HTTPClient
+	if c == nil {
+		c = http.DefaultClient
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("key set url responded %s", resp.Status)
+	}
+	var raw json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// sessionFromClaims maps the claims of a tokenized session into a session
+func sessionFromClaims(claims map[string]interface{}) (*client.Session, error) {
+	sess := &client.Session{}
+	if s, ok := claims["session"]; ok {
+		d, err := json.Marshal(s)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(d, sess); err != nil {
+			return nil, err
+		}
+	}
+	if sid, ok := claims["sid"].(string); ok && sess.Id == "" {
+		sess.Id = sid
+	}
+	if sub, ok := claims["sub"].(string); ok && sess.Identity.Id == "" {
+		sess.Identity.Id = sub
+	}
+	if sess.Identity.Id == "" {
+		return nil, errorx.New("sub claim is missing")
+	}
+	if traits, ok := claims["traits"]; ok && sess.Identity.Traits == nil {
+		sess.Identity.Traits = traits
+	}
+	if meta, ok := claims["metadata_public"]; ok && sess.Identity.MetadataPublic == nil {
+		sess.Identity.MetadataPublic = meta
+	}
+	if schema, ok := claims["schema_id"].(string); ok && sess.Identity.SchemaId == "" {
+		sess.Identity.SchemaId = schema
+	}
+	if exp, ok := claims["exp"].(float64); ok && sess.ExpiresAt == nil {
+		t := time.Unix(int64(exp), 0)
+		sess.ExpiresAt = &t
+	}
+	if iat, ok := claims["iat"].(float64); ok && sess.IssuedAt == nil {
+		t := time.Unix(int64(iat), 0)
+		sess.IssuedAt = &t
+	}
+	if sess.Active == nil {
+		sess.Active = pointer.Bool(true)
+	}
+	return sess, nil
+}
+
+// bearer returns the token from an authorization header value
+func bearer(header string) string {
+	if len(header) > 7 && strings.EqualFold(header[:7], "bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
+}
+
+func containsAny(audience jwt.Audience, accepted []string) bool {
+	for _, a := range accepted {
+		if audience.Contains(a) {
+			return true
+		}
+	}
+	return false
+}
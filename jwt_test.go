@@ -0,0 +1,173 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+
+	"github.com/w6d-io/kratox"
+)
+
+// newSigner returns a RS256 signer and the public key set holding its key
+func newSigner(t *testing.T, kid string) (jose.Signer, jose.JSONWebKeySet) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key}, (&jose.SignerOptions{}).WithHeader("kid", kid))
+	if err != nil {
+		t.Fatalf("new signer: %v", err)
+	}
+	return signer, jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: &key.PublicKey, KeyID: kid, Algorithm: string(jose.RS256), Use: "sig"}}}
+}
+
+func sign(t *testing.T, signer jose.Signer, claims ...interface{}) string {
+	b := jwt.Signed(signer)
+	for _, c := range claims {
+		b = b.Claims(c)
+	}
+	token, err := b.CompactSerialize()
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return token
+}
+
+func TestJWTVerifier_VerifySession(t *testing.T) {
+	signer, jwks := newSigner(t, "k1")
+	other, _ := newSigner(t, "k1")
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	d, _ := json.Marshal(jwks)
+	if err := os.WriteFile(file, d, 0600); err != nil {
+		t.Fatalf("write jwks: %v", err)
+	}
+	v := &kratox.JWTVerifier{
+		Keys:     &kratox.JWKS{File: file},
+		Issuer:   "https://kratos.example.com",
+		Audience: []string{"api"},
+	}
+	now := time.Now()
+	valid := jwt.Claims{
+		Issuer:   "https://kratos.example.com",
+		Subject:  "identity-id",
+		Audience: jwt.Audience{"api"},
+		Expiry:   jwt.NewNumericDate(now.Add(time.Minute)),
+		IssuedAt: jwt.NewNumericDate(now),
+	}
+	expired := valid
+	expired.Expiry = jwt.NewNumericDate(now.Add(-time.Minute))
+	issuer := valid
+	issuer.Issuer = "https://evil.example.com"
+	audience := valid
+	audience.Audience = jwt.Audience{"other"}
+	noExpiry := valid
+	noExpiry.Expiry = nil
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "valid", token: sign(t, signer, valid, map[string]interface{}{"sid": "session-id", "traits": map[string]string{"email": "a@b.c"}})},
+		{name: "expired", token: sign(t, signer, expired), wantErr: true},
+		{name: "wrong issuer", token: sign(t, signer, issuer), wantErr: true},
+		{name: "wrong audience", token: sign(t, signer, audience), wantErr: true},
+		{name: "no expiry", token: sign(t, signer, noExpiry), wantErr: true},
+		{name: "wrong key", token: sign(t, other, valid), wantErr: true},
+		{name: "not a jwt", token: "ory_st_token", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := v.VerifySession(context.Background(), tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifySession() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Id != "session-id" || got.Identity.Id != "identity-id" || !got.GetActive() {
+				t.Errorf("VerifySession() = %+v", got)
+			}
+			if traits, _ := got.Identity.Traits.(map[string]interface{}); traits["email"] != "a@b.c" {
+				t.Errorf("VerifySession() traits = %v", got.Identity.Traits)
+			}
+		})
+	}
+}
+
+func TestJWTVerifier_AuthRequestFunc(t *testing.T) {
+	signer, jwks := newSigner(t, "k1")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(jwks)
+	}))
+	defer srv.Close()
+	v := &kratox.JWTVerifier{Keys: &kratox.JWKS{URL: srv.URL}}
+	token := sign(t, signer, jwt.Claims{Subject: "identity-id", Expiry: jwt.NewNumericDate(time.Now().Add(time.Minute))})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	sess, err := kratox.GetSessionFromCtx(v.AuthRequestFunc(context.Background(), req))
+	if err != nil {
+		t.Fatalf("AuthRequestFunc() did not record the session: %v", err)
+	}
+	if sess.Identity.Id != "identity-id" {
+		t.Errorf("AuthRequestFunc() identity = %v", sess.Identity.Id)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	if _, err := kratox.GetSessionFromCtx(v.AuthRequestFunc(context.Background(), req)); err == nil {
+		t.Errorf("AuthRequestFunc() recorded a session without bearer token")
+	}
+}
+
+func TestTokenizeSession(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/sessions/whoami" || r.URL.Query().Get("tokenize_as") != "jwt_template" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if c, err := r.Cookie(kratox.CookieName); err != nil || c.Value != "test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":        "session-id",
+			"identity":  map[string]interface{}{"id": "identity-id", "schema_id": "default", "schema_url": "", "traits": map[string]string{}},
+			"tokenized": "header.payload.signature",
+		})
+	}))
+	defer srv.Close()
+	kratox.SetAddress(srv.URL, srv.URL)
+
+	token, err := kratox.Kratox.TokenizeSession(kratox.SetCookieInCtx(context.Background(), "test"), "jwt_template")
+	if err != nil || token != "header.payload.signature" {
+		t.Errorf("TokenizeSession() = %v, %v", token, err)
+	}
+	if _, err := kratox.Kratox.TokenizeSession(context.Background(), "jwt_template"); err == nil {
+		t.Errorf("TokenizeSession() without cookie should fail")
+	}
+}
@@ -0,0 +1,238 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	client "github.com/ory/kratos-client-go"
+
+	"github.com/w6d-io/x/errorx"
+	"github.com/w6d-io/x/logx"
+)
+
+// ketoCacheSize bounds the decision cache, it is flushed when full
+const ketoCacheSize = 10000
+
+// Keto checks relation tuples against the ory keto read api
+type Keto struct {
+	// Address is the address to the keto read api
+	Address string `json:"address" mapstructure:"address"`
+	// CacheTTL is how long a decision is kept, decisions are not cached when zero
+	CacheTTL time.Duration `json:"cacheTTL" mapstructure:"cacheTTL"`
+	// HTTPClient is the client used to call keto, http.DefaultClient when nil
+	HTTPClient *http.Client `json:"-" mapstructure:"-"`
+
+	mu    sync.Mutex
+	cache map[RelationTuple]decision
+}
+
+// RelationTuple is the keto check request, the subject is the identity id
+type RelationTuple struct {
+	Namespace string `json:"namespace"`
+	Object    string `json:"object"`
+	Relation  string `json:"relation"`
+	SubjectID string `json:"subject_id"`
+}
+
+type decision struct {
+	allowed bool
+	expire  time.Time
+}
+
+type checkResponse struct {
+	Allowed bool   `json:"allowed"`
+	Error   string `json:"error,omitempty"`
+}
+
+type batchCheckRequest struct {
+	Tuples []RelationTuple `json:"tuples"`
+}
+
+type batchCheckResponse struct {
+	Results []checkResponse `json:"results"`
+}
+
+// NewKeto returns a keto checker caching decisions for ttl
+func NewKeto(address string, ttl time.Duration) *Keto {
+	return &Keto{Address: address, CacheTTL: ttl}
+}
+
+// Check reports whether the relation tuple exists
+func (k *Keto) Check(ctx context.Context, tuple RelationTuple) (bool, error) {
+	log := logx.WithName(ctx, "KetoCheck")
+	if allowed, ok := k.cached(tuple); ok {
+		return allowed, nil
+	}
+	u, err := k.getKetoAddress()
+	if err != nil {
+		return false, errorx.NewHTTP(err, http.StatusInternalServerError, "fail to get keto address")
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/relation-tuples/check/openapi"
+	q := url.Values{}
+	q.Set("namespace", tuple.Namespace)
+	q.Set("object", tuple.Object)
+	q.Set("relation", tuple.Relation)
+	q.Set("subject_id", tuple.SubjectID)
+	u.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return false, errorx.NewHTTP(err, http.StatusInternalServerError, "build keto request failed")
+	}
+	var rsp checkResponse
+	if err := k.call(req, &rsp); err != nil {
+		log.Error(err, "calling fail", "name", "Check", "tuple", tuple)
+		return false, err
+	}
+	k.store(tuple, rsp.Allowed)
+	return rsp.Allowed, nil
+}
+
+// BatchCheck reports whether each relation tuple exists, results keep the tuples order.
+// Only the tuples missing from the cache are sent to keto
+func (k *Keto) BatchCheck(ctx context.Context, tuples []RelationTuple) ([]bool, error) {
+	log := logx.WithName(ctx, "KetoBatchCheck")
+	results := make([]bool, len(tuples))
+	var missing []RelationTuple
+	var index []int
+	for i, tuple := range tuples {
+		if allowed, ok := k.cached(tuple); ok {
+			results[i] = allowed
+			continue
+		}
+		missing = append(missing, tuple)
+		index = append(index, i)
+	}
+	if len(missing) == 0 {
+		return results, nil
+	}
+	u, err := k.getKetoAddress()
+	if err != nil {
+		return nil, errorx.NewHTTP(err, http.StatusInternalServerError, "fail to get keto address")
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/relation-tuples/batch/check"
+	body, err := json.Marshal(batchCheckRequest{Tuples: missing})
+	if err != nil {
+		return nil, errorx.NewHTTP(err, http.StatusInternalServerError, "marshal tuples failed")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, errorx.NewHTTP(err, http.StatusInternalServerError, "build keto request failed")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	var rsp batchCheckResponse
+	if err := k.call(req, &rsp); err != nil {
+		log.Error(err, "calling fail", "name", "BatchCheck")
+		return nil, err
+	}
+	if len(rsp.Results) != len(missing) {
+		err := fmt.Errorf("keto returned %d results for %d tuples", len(rsp.Results), len(missing))
+		log.Error(err, "unexpected batch response")
+		return nil, errorx.NewHTTP(err, http.StatusBadGateway, "unexpected keto response")
+	}
+	for i, result := range rsp.Results {
+		if result.Error != "" {
+			log.Info("check failed", "tuple", missing[i], "error", result.Error)
+			continue
+		}
+		results[index[i]] = result.Allowed
+		k.store(missing[i], result.Allowed)
+	}
+	return results, nil
+}
+
+// CheckSession checks the relation for the identity of the session stored into the context.
+// It returns a StatusUnauthorized error when there is no session and a StatusForbidden
+// error when the relation does not exist
+func (k *Keto) CheckSession(ctx context.Context, namespace, object, relation string) error {
+	return Authorize(ctx, Permission(k, namespace, object, relation))
+}
+
+// Permission is a predicate satisfied when the session identity holds the relation on the object,
+// it can be bound to routes and gRPC methods in a Policy
+func Permission(k *Keto, namespace, object, relation string) Predicate {
+	name := fmt.Sprintf("%s:%s#%s", namespace, object, relation)
+	return NewPredicate(name, func(ctx context.Context, s *client.Session) (bool, error) {
+		return k.Check(ctx, RelationTuple{
+			Namespace: namespace,
+			Object:    object,
+			Relation:  relation,
+			SubjectID: s.Identity.Id,
+		})
+	})
+}
+
+// call sends the request and decode the json response into out
+func (k *Keto) call(req *http.Request, out interface{}) error {
+	c := k.HTTPClient
+	if c == nil {
+		c = http.DefaultClient
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return errorx.NewHTTP(err, http.StatusInternalServerError, "fail to call keto")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errorx.NewHTTP(fmt.Errorf("keto responded %s", resp.Status), resp.StatusCode, "fail to call keto")
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return errorx.NewHTTP(err, http.StatusInternalServerError, "decode keto response failed")
+	}
+	return nil
+}
+
+func (k *Keto) cached(tuple RelationTuple) (bool, bool) {
+	if k.CacheTTL <= 0 {
+		return false, false
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	d, ok := k.cache[tuple]
+	if !ok {
+		return false, false
+	}
+	if time.Now().After(d.expire) {
+		delete(k.cache, tuple)
+		return false, false
+	}
+	return d.allowed, true
+}
+
+func (k *Keto) store(tuple RelationTuple, allowed bool) {
+	if k.CacheTTL <= 0 {
+		return
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.cache == nil || len(k.cache) >= ketoCacheSize {
+		k.cache = make(map[RelationTuple]decision)
+	}
+	k.cache[tuple] = decision{allowed: allowed, expire: time.Now().Add(k.CacheTTL)}
+}
+
+// getKetoAddress parses the keto address, http is used when the scheme is missing
+func (k *Keto) getKetoAddress() (*url.URL, error) {
+	return Conn{Address: k.Address}.getKratosAddress()
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/w6d-io/x/errorx"
+
+	"github.com/w6d-io/kratox"
+)
+
+// fakeKeto answers checks from the tuples it holds
+func fakeKeto(t *testing.T, calls *int32, tuples ...kratox.RelationTuple) *httptest.Server {
+	allowed := func(tuple kratox.RelationTuple) bool {
+		for _, tu := range tuples {
+			if tu == tuple {
+				return true
+			}
+		}
+		return false
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/relation-tuples/check/openapi", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		q := r.URL.Query()
+		_ = json.NewEncoder(w).Encode(map[string]bool{"allowed": allowed(kratox.RelationTuple{
+			Namespace: q.Get("namespace"),
+			Object:    q.Get("object"),
+			Relation:  q.Get("relation"),
+			SubjectID: q.Get("subject_id"),
+		})})
+	})
+	mux.HandleFunc("/relation-tuples/batch/check", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		var body struct {
+			Tuples []kratox.RelationTuple `json:"tuples"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode batch body: %v", err)
+		}
+		var results []map[string]bool
+		for _, tuple := range body.Tuples {
+			results = append(results, map[string]bool{"allowed": allowed(tuple)})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestKeto_Check(t *testing.T) {
+	var calls int32
+	owner := kratox.RelationTuple{Namespace: "projects", Object: "p1", Relation: "owner", SubjectID: "admin"}
+	srv := fakeKeto(t, &calls, owner)
+	defer srv.Close()
+
+	k := kratox.NewKeto(srv.URL, time.Minute)
+	for i := 0; i < 2; i++ {
+		got, err := k.Check(context.Background(), owner)
+		if err != nil || !got {
+			t.Errorf("Check() = %v, %v, want true", got, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Check() made %d calls, want 1 thanks to the cache", calls)
+	}
+	got, err := k.Check(context.Background(), kratox.RelationTuple{Namespace: "projects", Object: "p2", Relation: "owner", SubjectID: "admin"})
+	if err != nil || got {
+		t.Errorf("Check() = %v, %v, want false", got, err)
+	}
+}
+
+func TestKeto_BatchCheck(t *testing.T) {
+	var calls int32
+	owner := kratox.RelationTuple{Namespace: "projects", Object: "p1", Relation: "owner", SubjectID: "admin"}
+	viewer := kratox.RelationTuple{Namespace: "projects", Object: "p2", Relation: "viewer", SubjectID: "admin"}
+	other := kratox.RelationTuple{Namespace: "projects", Object: "p3", Relation: "owner", SubjectID: "admin"}
+	srv := fakeKeto(t, &calls, owner, viewer)
+	defer srv.Close()
+
+	k := kratox.NewKeto(srv.URL, time.Minute)
+	if _, err := k.Check(context.Background(), owner); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	got, err := k.BatchCheck(context.Background(), []kratox.RelationTuple{owner, other, viewer})
+	if err != nil {
+		t.Fatalf("BatchCheck() error = %v", err)
+	}
+	if want := []bool{true, false, true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("BatchCheck() = %v, want %v", got, want)
+	}
+	if _, err := k.BatchCheck(context.Background(), []kratox.RelationTuple{owner, other, viewer}); err != nil {
+		t.Fatalf("BatchCheck() error = %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("BatchCheck() made %d calls, want 2", calls)
+	}
+}
+
+func TestKeto_CheckSession(t *testing.T) {
+	var calls int32
+	srv := fakeKeto(t, &calls, kratox.RelationTuple{Namespace: "projects", Object: "p1", Relation: "owner", SubjectID: admin.Identity.Id})
+	defer srv.Close()
+
+	k := kratox.NewKeto(srv.URL, 0)
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		object string
+		want   int
+	}{
+		{name: "no session", ctx: context.Background(), object: "p1", want: http.StatusUnauthorized},
+		{name: "allowed", ctx: kratox.SetSessionInCtx(context.Background(), admin), object: "p1", want: 0},
+		{name: "forbidden", ctx: kratox.SetSessionInCtx(context.Background(), admin), object: "p2", want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := k.CheckSession(tt.ctx, "projects", tt.object, "owner")
+			if tt.want == 0 {
+				if err != nil {
+					t.Errorf("CheckSession() error = %v", err)
+				}
+				return
+			}
+			var e *errorx.Error
+			if !errors.As(err, &e) || e.StatusCode != tt.want {
+				t.Errorf("CheckSession() error = %v, want status %d", err, tt.want)
+			}
+		})
+	}
+}
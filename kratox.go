@@ -2,8 +2,10 @@ package kratox
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"net/url"
+	"time"
 
 	client "github.com/ory/kratos-client-go"
 	"github.com/w6d-io/x/errorx"
@@ -14,6 +16,18 @@ type Conn struct {
 	Address string `json:"address" mapstructure:"address"`
 	//AdminAddress is the kratos admin address
 	AdminAddress string `json:"adminAddress" mapstructure:"adminAddress"`
+	// CookieName is the name of the session cookie sent to kratos, the kratos session.cookie.name setting.
+	// CookieName when empty, the cookie extractors only select the cookie of the incoming requests
+	CookieName string `json:"cookieName" mapstructure:"cookieName"`
+}
+
+// SessionSource resolves the session of an incoming http request or gRPC call
+type SessionSource interface {
+	// GetSessionFromHTTP returns the session of the http request
+	GetSessionFromHTTP(context.Context, *http.Request) (*client.Session, error)
+
+	// GetSessionFromGRPCCtx returns the session of the gRPC call
+	GetSessionFromGRPCCtx(context.Context) (*client.Session, error)
 }
 
 type Helper interface {
@@ -45,12 +59,64 @@ type Helper interface {
 	// to make the api call
 	GetIdentityFromCtx(context.Context) (*client.Identity, error)
 
-	// GetToken returns all tokens linked with the provider
+	// ListAllIdentities lists the identities of all the pages
+	// if kratos is unreachable or an other issues, return nil with statusCode of the call and error-go
+	ListAllIdentities(context.Context) ([]client.Identity, error)
+
+	// GetToken returns the tokens of the first account linked with the provider
+	// if the provider is not linked, return ErrProviderNotFound with the 404 status
 	GetToken(context.Context, string) (*Provider, error)
 
 	// GetTokens returns all tokens linked with the provider
 	GetTokens(context.Context) ([]Provider, error)
 
+	// GetProviderAccounts returns the tokens of all the accounts linked with the provider
+	// if the provider is not linked, return ErrProviderNotFound with the 404 status
+	GetProviderAccounts(context.Context, string) ([]Provider, error)
+
+	// GetIdentityProviders returns the tokens of all the accounts linked with the identity id
+	GetIdentityProviders(context.Context, string) ([]Provider, error)
+
+	// LinkOIDCProvider links the provider subject with the identity id
+	// if the subject is linked with another identity, return ErrSubjectLinked with the 409 status
+	LinkOIDCProvider(context.Context, string, string, string) (*client.Identity, error)
+
+	// UnlinkOIDCProvider removes the link of the provider subject from the identity id
+	// if the identity does not hold it, return ErrProviderNotFound with the 404 status
+	UnlinkOIDCProvider(context.Context, string, string, string) error
+
+	// CreateIdentityWithHashedPassword creates the identity with the password hash imported from another system
+	// if kratos is unreachable or an other issues, return nil with statusCode of the call and error-go
+	CreateIdentityWithHashedPassword(context.Context, string, map[string]interface{}, string) (*client.Identity, error)
+
+	// ListCourierMessages lists the messages of the kratos courier matching the filter
+	// if kratos is unreachable or an other issues, return nil with statusCode of the call and error-go
+	ListCourierMessages(context.Context, MessageFilter) (*MessagePage, error)
+
+	// GetCourierMessage returns the courier message with the id
+	// if kratos is unreachable or an other issues, return nil with statusCode of the call and error-go
+	GetCourierMessage(context.Context, string) (*client.Message, error)
+
+	// GetIdentityWithCredentialTypes gets the identity id with the typed config of the credential types
+	// if kratos is unreachable or an other issues, return nil with statusCode of the call and error-go
+	GetIdentityWithCredentialTypes(context.Context, string, ...CredentialType) (*IdentityCredentials, error)
+
+	// GetCredentialTypes returns the types of the credentials held by the identity id
+	GetCredentialTypes(context.Context, string) ([]string, error)
+
+	// DeleteCredential removes the credential type from the identity id
+	// if kratos is unreachable or an other issues, return statusCode of the call and error-go
+	DeleteCredential(context.Context, string, client.IdentityCredentialsType) error
+
+	// DeleteWebAuthnKey removes the webauthn key from the identity id, when it is the last one
+	// if the identity does not hold it, return ErrCredentialNotFound with the 404 status
+	// if the identity holds other keys, return the 501 status
+	DeleteWebAuthnKey(context.Context, string, string) error
+
+	// ResetMFA removes the second factor credentials of the identity id, the passwordless webauthn keys are kept
+	// if they are held along with second factor keys, return the 409 status
+	ResetMFA(context.Context, string) error
+
 	// UpdateIdentity is used to Update the identity with user id on kratos service
 	// if kratos is unreachable or an other issues, return nil session with statusCode of the call and error-go
 	// @params
@@ -65,12 +131,67 @@ type Helper interface {
 	DeleteIdentity(context.Context, string) error
 
 	PatchIdentity(context.Context, string, []client.JsonPatch) (*client.Identity, error)
+
+	// TokenizeSession exchanges the session cookie recorded into the context for a json web token
+	// built by the kratos session tokenizer with the template
+	// if kratos is unreachable or an other issues, return an empty token with statusCode of the call and error-go
+	TokenizeSession(context.Context, string) (string, error)
+
+	// Login performs an api login flow and returns the session with its session token
+	// if kratos rejects the submission, return a *FlowError holding the field messages
+	// if kratos is unreachable or an other issues, return nil with statusCode of the call and error-go
+	Login(context.Context, LoginRequest) (*client.SuccessfulNativeLogin, error)
+
+	// Register performs an api registration flow and returns the identity, with its session when
+	// the session hook is enabled
+	// if kratos rejects the submission, return a *FlowError holding the field messages
+	// if kratos is unreachable or an other issues, return nil with statusCode of the call and error-go
+	Register(context.Context, RegistrationRequest) (*client.SuccessfulNativeRegistration, error)
+
+	// CreateRecoveryLink creates a recovery link for the identity id, valid for the duration
+	// if kratos is unreachable or an other issues, return nil with statusCode of the call and error-go
+	CreateRecoveryLink(context.Context, string, time.Duration) (*client.RecoveryLinkForIdentity, error)
+
+	// CreateRecoveryCode creates a recovery code for the identity id, valid for the duration
+	// if kratos is unreachable or an other issues, return nil with statusCode of the call and error-go
+	CreateRecoveryCode(context.Context, string, time.Duration) (*client.RecoveryCodeForIdentity, error)
+
+	// GetIdentityByIdentifier returns the identity holding the credential identifier, like the email
+	// if there is none, return ErrIdentityNotFound with the 404 status
+	GetIdentityByIdentifier(context.Context, string) (*client.Identity, error)
+
+	// CreateRecoveryLinkForEmail creates a recovery link for the identity with the email
+	CreateRecoveryLinkForEmail(context.Context, string, time.Duration) (*client.RecoveryLinkForIdentity, error)
+
+	// CreateRecoveryCodeForEmail creates a recovery code for the identity with the email
+	CreateRecoveryCodeForEmail(context.Context, string, time.Duration) (*client.RecoveryCodeForIdentity, error)
+
+	// SendVerificationCode creates a verification flow and sends the code to the email, returns the flow id
+	// if kratos is unreachable or an other issues, return an empty id with statusCode of the call and error-go
+	SendVerificationCode(context.Context, string) (string, error)
+
+	// VerifyAddress submits the verification code on the flow
+	// if the code is not accepted, return a *FlowError holding the messages
+	VerifyAddress(context.Context, string, string) error
+
+	// GetVerifiableAddresses returns the addresses of the identity id with their verification status
+	// if kratos is unreachable or an other issues, return nil with statusCode of the call and error-go
+	GetVerifiableAddresses(context.Context, string) ([]client.VerifiableIdentityAddress, error)
+
+	// IsAddressVerified reports whether the address of the identity id is verified
+	IsAddressVerified(context.Context, string, string) (bool, error)
+
+	// UpdateSettings submits a settings flow for the session cookie or token recorded into the context
+	// if kratos rejects the submission, return a *FlowError holding the field messages
+	// if the session has to be refreshed, return a *PrivilegedSessionError
+	UpdateSettings(context.Context, SettingsRequest) (*SettingsResult, error)
 }
 
 type Provider struct {
 	TokenID      string `json:"initial_id_token"`
 	Subject      string `json:"subject"`
 	Provider     string `json:"provider"`
+	Organization string `json:"organization"`
 	AccessToken  string `json:"initial_access_token"`
 	RefreshToken string `json:"initial_refresh_token"`
 }
@@ -83,6 +204,9 @@ type auth struct {
 
 var (
 	Kratox Helper
+
+	// Source is the session source used by AuthRequestFunc and AuthGRPCFunc, Kratox when nil
+	Source SessionSource
 )
 
 type ContextKey int
@@ -91,6 +215,7 @@ const (
 	AddressKey ContextKey = iota
 	SessionKey
 	CookieKey
+	TokenKey
 )
 
 const (
@@ -127,6 +252,63 @@ func (k Conn) getKratosAdminAddress() (*url.URL, error) {
 	return u, nil
 }
 
+// adminAPI returns the kratos client targeting the admin address
+func (k Conn) adminAPI() (*client.APIClient, error) {
+	u, err := k.getKratosAdminAddress()
+	if err != nil {
+		return nil, errorx.NewHTTP(err, http.StatusInternalServerError, "fail to get kratos address")
+	}
+	return newAPIClient(u), nil
+}
+
+// publicAPI returns the kratos client targeting the public address
+func (k Conn) publicAPI() (*client.APIClient, error) {
+	u, err := k.getKratosAddress()
+	if err != nil {
+		return nil, errorx.NewHTTP(err, http.StatusInternalServerError, "fail to get kratos address")
+	}
+	return newAPIClient(u), nil
+}
+
+// newAPIClient returns the kratos client targeting u, its calls go through the retry and the circuit breaker
+func newAPIClient(u *url.URL) *client.APIClient {
+	cfg := client.NewConfiguration()
+	cfg.Scheme = u.Scheme
+	cfg.Host = u.Host
+	cfg.Servers = []client.ServerConfiguration{
+		{
+			URL: u.String(),
+		},
+	}
+	cfg.HTTPClient = httpClient
+	return client.NewAPIClient(cfg)
+}
+
+// statusOf returns the status code of the kratos response, 503 when the circuit breaker
+// rejected the call and 500 when kratos did not respond
+func statusOf(rsp *http.Response, err error) int {
+	if errors.Is(err, ErrKratosUnavailable) {
+		return http.StatusServiceUnavailable
+	}
+	if rsp == nil {
+		return http.StatusInternalServerError
+	}
+	return rsp.StatusCode
+}
+
 func SetAddress(address, adminAddress string) {
 	Kratox = &auth{Conn{Address: address, AdminAddress: adminAddress}}
 }
+
+// SetConn sets the kratos helper with the whole connection config
+func SetConn(conn Conn) {
+	Kratox = &auth{conn}
+}
+
+// sessionCookie returns the name of the session cookie sent to kratos
+func (k Conn) sessionCookie() string {
+	if k.CookieName == "" {
+		return CookieName
+	}
+	return k.CookieName
+}
@@ -44,3 +44,30 @@ func (k kratosMock) GetSessionFromHTTP(_ context.Context, _ *http.Request) (*cli
 		return session, nil
 	}
 }
+
+func (k kratosMock) GetSessionFromGRPCCtx(_ context.Context) (*client.Session, error) {
+	switch k.behaviour {
+	case "ko":
+		return nil, errors.New("failed to connect")
+	case "sessionNil":
+		return nil, nil
+	default:
+		return session, nil
+	}
+}
+
+func (k kratosMock) GetToken(_ context.Context, providerID string) (*kratox.Provider, error) {
+	switch {
+	case k.behaviour == "ko":
+		return nil, errors.New("failed to connect")
+	case !k.token || providerID != k.provider:
+		return nil, kratox.ErrProviderNotFound
+	default:
+		return &kratox.Provider{
+			Provider:     k.provider,
+			Subject:      k.subject,
+			AccessToken:  "initial-access-token",
+			RefreshToken: "initial-refresh-token",
+		}, nil
+	}
+}
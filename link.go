@@ -0,0 +1,123 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	client "github.com/ory/kratos-client-go"
+
+	"github.com/w6d-io/x/errorx"
+	"github.com/w6d-io/x/logx"
+)
+
+var (
+	// ErrSubjectLinked is returned when the provider subject is linked with another identity
+	ErrSubjectLinked = errorx.New("subject already linked with another identity")
+)
+
+// LinkOIDCProvider links the account of the provider with the identity, kratos imports the
+// oidc credential. Linking again a subject linked with the identity does nothing, the subject
+// linked with another identity is rejected with ErrSubjectLinked and the 409 status
+// if kratos is unreachable or an other issues, return nil with statusCode of the call and error-go
+func (a auth) LinkOIDCProvider(ctx context.Context, identityID, provider, subject string) (*client.Identity, error) {
+	log := logx.WithName(ctx, "LinkOIDCProvider")
+	// kratos does not search the identities by oidc identifier, the links of the identity are read
+	providers, err := a.GetIdentityProviders(ctx, identityID)
+	if err != nil {
+		return nil, err
+	}
+	i, err := a.GetIdentity(ctx, identityID)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range providers {
+		if p.Provider == provider && p.Subject == subject {
+			log.V(1).Info("provider already linked", "id", identityID, "provider", provider)
+			return i, nil
+		}
+	}
+	api, err := a.adminAPI()
+	if err != nil {
+		return nil, err
+	}
+	traits, _ := i.Traits.(map[string]interface{})
+	state := i.GetState()
+	if state == "" {
+		state = client.IDENTITYSTATE_ACTIVE
+	}
+	body := client.NewUpdateIdentityBody(i.SchemaId, state, traits)
+	body.MetadataPublic = i.MetadataPublic
+	body.MetadataAdmin = i.MetadataAdmin
+	body.Credentials = &client.IdentityWithCredentials{
+		Oidc: &client.IdentityWithCredentialsOidc{
+			Config: &client.IdentityWithCredentialsOidcConfig{
+				Providers: []client.IdentityWithCredentialsOidcConfigProvider{
+					*client.NewIdentityWithCredentialsOidcConfigProvider(provider, subject),
+				},
+			},
+		},
+	}
+	updated, r, err := api.IdentityApi.UpdateIdentity(ctx, identityID).UpdateIdentityBody(*body).Execute()
+	if err != nil {
+		// the oidc identifier is unique, kratos rejects the subject linked with another identity
+		if statusOf(r, err) == http.StatusConflict {
+			log.Error(ErrSubjectLinked, "link provider failed", "provider", provider)
+			return nil, errorx.NewHTTP(ErrSubjectLinked, http.StatusConflict, "subject already linked")
+		}
+		log.Error(err, "calling fail", "name", "UpdateIdentity", "response", r)
+		return nil, errorx.NewHTTP(err, statusOf(r, err), "fail to call kratos")
+	}
+	log.V(1).Info("provider linked", "id", identityID, "provider", provider)
+	return updated, nil
+}
+
+// UnlinkOIDCProvider removes the link of the provider account from the identity.
+// It returns ErrProviderNotFound with the 404 status when the identity does not hold it.
+// The removal of a single oidc link needs kratos v1.1 or later
+// if kratos is unreachable or an other issues, return statusCode of the call and error-go
+func (a auth) UnlinkOIDCProvider(ctx context.Context, identityID, provider, subject string) error {
+	log := logx.WithName(ctx, "UnlinkOIDCProvider")
+	providers, err := a.GetIdentityProviders(ctx, identityID)
+	if err != nil {
+		return err
+	}
+	linked := false
+	for _, p := range providers {
+		if p.Provider == provider && p.Subject == subject {
+			linked = true
+		}
+	}
+	if !linked {
+		log.Error(ErrProviderNotFound, "unlink provider failed", "provider", provider)
+		return errorx.NewHTTP(ErrProviderNotFound, http.StatusNotFound, "provider not linked")
+	}
+	path := "/admin/identities/" + url.PathEscape(identityID) + "/credentials/" + string(client.IDENTITYCREDENTIALSTYPE_OIDC)
+	query := url.Values{"identifier": []string{oidcIdentifier(provider, subject)}}
+	if err := a.adminCall(ctx, http.MethodDelete, path, query, nil, nil); err != nil {
+		log.Error(err, "delete oidc link failed", "provider", provider)
+		return err
+	}
+	log.V(1).Info("provider unlinked", "id", identityID, "provider", provider)
+	return nil
+}
+
+// oidcIdentifier is the identifier of the oidc credential of the provider subject
+func oidcIdentifier(provider, subject string) string {
+	return provider + ":" + subject
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/w6d-io/kratox"
+	"github.com/w6d-io/x/errorx"
+)
+
+// fakeLinks is a kratos admin api holding the oidc links by identity. As kratos, the
+// credentials_identifier filter ignores the oidc identifiers and the import of an oidc
+// identifier held by another identity is a conflict
+type fakeLinks struct {
+	mu      sync.Mutex
+	links   map[string][]map[string]string
+	updates int
+}
+
+// holder returns the identity holding the oidc identifier
+func (f *fakeLinks) holder(link map[string]string) string {
+	for id, links := range f.links {
+		for _, l := range links {
+			if l["provider"] == link["provider"] && l["subject"] == link["subject"] {
+				return id
+			}
+		}
+	}
+	return ""
+}
+
+func (f *fakeLinks) identity(id string) map[string]interface{} {
+	providers := []interface{}{}
+	for _, l := range f.links[id] {
+		providers = append(providers, l)
+	}
+	return map[string]interface{}{
+		"id": id, "schema_id": "default", "schema_url": "", "state": "active",
+		"traits":          map[string]interface{}{"email": id + "@b.c"},
+		"metadata_public": map[string]interface{}{"roles": []string{"admin"}},
+		"credentials": map[string]interface{}{
+			"oidc": map[string]interface{}{"type": "oidc", "config": map[string]interface{}{"providers": providers}},
+		},
+	}
+}
+
+func (f *fakeLinks) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/admin/identities"), "/")
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		_ = json.NewEncoder(w).Encode([]interface{}{})
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		_ = json.NewEncoder(w).Encode(f.identity(parts[1]))
+	case len(parts) == 2 && r.Method == http.MethodPut:
+		var body struct {
+			MetadataPublic map[string]interface{} `json:"metadata_public"`
+			Credentials    struct {
+				Oidc struct {
+					Config struct {
+						Providers []map[string]string `json:"providers"`
+					} `json:"config"`
+				} `json:"oidc"`
+			} `json:"credentials"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.MetadataPublic == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.updates++
+		for _, l := range body.Credentials.Oidc.Config.Providers {
+			if holder := f.holder(l); holder != "" && holder != parts[1] {
+				w.WriteHeader(http.StatusConflict)
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{
+					"error": map[string]interface{}{"code": 409, "status": "Conflict", "message": "an identity with the same identifier already exists"},
+				})
+				return
+			}
+		}
+		f.links[parts[1]] = append(f.links[parts[1]], body.Credentials.Oidc.Config.Providers...)
+		_ = json.NewEncoder(w).Encode(f.identity(parts[1]))
+	case len(parts) == 4 && r.Method == http.MethodDelete && parts[3] == "oidc":
+		var kept []map[string]string
+		for _, l := range f.links[parts[1]] {
+			if l["provider"]+":"+l["subject"] != r.URL.Query().Get("identifier") {
+				kept = append(kept, l)
+			}
+		}
+		f.links[parts[1]] = kept
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestLinkOIDCProvider(t *testing.T) {
+	fake := &fakeLinks{links: map[string][]map[string]string{
+		"alice": {{"provider": "google", "subject": "alice-google"}},
+		"bob":   nil,
+		"carol": nil,
+	}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	kratox.SetAddress(srv.URL, srv.URL)
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		id          string
+		provider    string
+		subject     string
+		wantErr     error
+		wantUpdates int
+	}{
+		{name: "link", id: "bob", provider: "github", subject: "bob-github", wantUpdates: 1},
+		{name: "already linked", id: "alice", provider: "google", subject: "alice-google"},
+		{name: "subject held by another identity", id: "carol", provider: "google", subject: "alice-google", wantErr: kratox.ErrSubjectLinked, wantUpdates: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake.updates = 0
+			got, err := kratox.Kratox.LinkOIDCProvider(ctx, tt.id, tt.provider, tt.subject)
+			if fake.updates != tt.wantUpdates {
+				t.Errorf("LinkOIDCProvider() updated the identity %d times, want %d", fake.updates, tt.wantUpdates)
+			}
+			if tt.wantErr != nil {
+				var e *errorx.Error
+				if !errors.Is(err, tt.wantErr) || !errors.As(err, &e) || e.StatusCode != http.StatusConflict {
+					t.Errorf("LinkOIDCProvider() error = %v, want %v with the 409 status", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got.Id != tt.id {
+				t.Fatalf("LinkOIDCProvider() = %v, %v", got, err)
+			}
+			providers, err := kratox.Kratox.GetIdentityProviders(ctx, tt.id)
+			if err != nil || len(providers) != 1 || providers[0].Subject != tt.subject {
+				t.Errorf("GetIdentityProviders() = %+v, %v", providers, err)
+			}
+		})
+	}
+}
+
+func TestUnlinkOIDCProvider(t *testing.T) {
+	fake := &fakeLinks{links: map[string][]map[string]string{
+		"alice": {{"provider": "google", "subject": "alice-google"}, {"provider": "github", "subject": "alice-github"}},
+	}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	kratox.SetAddress(srv.URL, srv.URL)
+	ctx := context.Background()
+
+	if err := kratox.Kratox.UnlinkOIDCProvider(ctx, "alice", "google", "other"); !errors.Is(err, kratox.ErrProviderNotFound) {
+		t.Errorf("UnlinkOIDCProvider() error = %v, want %v", err, kratox.ErrProviderNotFound)
+	}
+	if err := kratox.Kratox.UnlinkOIDCProvider(ctx, "alice", "google", "alice-google"); err != nil {
+		t.Fatalf("UnlinkOIDCProvider() error = %v", err)
+	}
+	providers, err := kratox.Kratox.GetIdentityProviders(ctx, "alice")
+	if err != nil || len(providers) != 1 || providers[0].Provider != "github" {
+		t.Errorf("GetIdentityProviders() = %+v, %v", providers, err)
+	}
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	client "github.com/ory/kratos-client-go"
+
+	"github.com/w6d-io/x/errorx"
+	"github.com/w6d-io/x/logx"
+)
+
+// LoginMethod is the method used to submit the login flow
+type LoginMethod string
+
+const (
+	// LoginWithPassword submits the identifier and the password
+	LoginWithPassword LoginMethod = "password"
+	// LoginWithCode submits the identifier to get a one-time code, then the code
+	LoginWithCode LoginMethod = "code"
+	// LoginWithTOTP submits the totp code for the second factor
+	LoginWithTOTP LoginMethod = "totp"
+	// LoginWithLookupSecret submits a backup code for the second factor
+	LoginWithLookupSecret LoginMethod = "lookup_secret"
+)
+
+var (
+	errUnknownLoginMethod = errorx.New("unknown login method")
+)
+
+// LoginRequest holds the parameters of an api login flow
+type LoginRequest struct {
+	// FlowID continues an existing flow, a new flow is created when empty
+	FlowID string
+	// Method used to submit the flow
+	Method LoginMethod
+	// Identifier is the login identifier, usually the email
+	Identifier string
+	// Password for the password method
+	Password string
+	// Code is the one-time code, the totp code or the lookup secret according to the method
+	Code string
+	// Refresh forces the re-authentication of the session
+	Refresh bool
+	// AAL is the requested authenticator assurance level (aal1, aal2)
+	AAL string
+	// SessionToken of the current session, required to refresh it or to reach aal2
+	SessionToken string
+}
+
+// Login performs an api login flow and returns the session and its session token.
+// A new flow is created unless FlowID is set. When kratos rejects the submission a *FlowError
+// holding the field messages is returned, its FlowID allows to submit the flow again.
+// With the code method, submitting without Code sends the code and returns a *FlowError
+// with the sent_email state, the code is then submitted on the same flow
+// if kratos is unreachable or an other issues, return nil with statusCode of the call and error-go
+func (a auth) Login(ctx context.Context, lr LoginRequest) (*client.SuccessfulNativeLogin, error) {
+	log := logx.WithName(ctx, "Login")
+	body := map[string]interface{}{"method": lr.Method}
+	switch lr.Method {
+	case LoginWithPassword:
+		body["identifier"] = lr.Identifier
+		body["password"] = lr.Password
+	case LoginWithCode:
+		if lr.Identifier != "" {
+			body["identifier"] = lr.Identifier
+		}
+		if lr.Code != "" {
+			body["code"] = lr.Code
+		}
+	case LoginWithTOTP:
+		body["totp_code"] = lr.Code
+	case LoginWithLookupSecret:
+		body["lookup_secret"] = lr.Code
+	default:
+		log.Error(errUnknownLoginMethod, "invalid login request", "method", lr.Method)
+		return nil, errorx.NewHTTP(errUnknownLoginMethod, http.StatusBadRequest, "unknown login method")
+	}
+	cred := Credential{Token: lr.SessionToken}
+
+	flowID := lr.FlowID
+	if flowID == "" {
+		q := url.Values{}
+		if lr.Refresh {
+			q.Set("refresh", strconv.FormatBool(lr.Refresh))
+		}
+		if lr.AAL != "" {
+			q.Set("aal", lr.AAL)
+		}
+		var flow flowBody
+		if err := a.flowCall(ctx, http.MethodGet, "/self-service/login/api", q, cred, nil, &flow); err != nil {
+			log.Error(err, "create login flow failed")
+			return nil, err
+		}
+		flowID = flow.ID
+	}
+
+	out := &client.SuccessfulNativeLogin{}
+	if err := a.flowCall(ctx, http.MethodPost, "/self-service/login", url.Values{"flow": []string{flowID}}, cred, body, out); err != nil {
+		return nil, err
+	}
+	log.V(1).Info("logged in", "identity", out.Session.Identity.Id)
+	return out, nil
+}
@@ -0,0 +1,175 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/w6d-io/kratox"
+)
+
+// flowWithMessages returns a flow body like kratos does on a rejected submission
+func flowWithMessages(id, state, field, text string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":    id,
+		"state": state,
+		"ui": map[string]interface{}{
+			"action": "http://kratos/self-service/login?flow=" + id,
+			"method": "POST",
+			"nodes": []interface{}{
+				map[string]interface{}{
+					"type":       "input",
+					"group":      "password",
+					"attributes": map[string]interface{}{"name": field, "type": "text", "node_type": "input", "disabled": false},
+					"messages":   []interface{}{map[string]interface{}{"id": 4000006, "text": text, "type": "error"}},
+					"meta":       map[string]interface{}{},
+				},
+			},
+		},
+	}
+}
+
+func fakeLogin(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/self-service/login/api", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("aal") == "aal2" && r.Header.Get(kratox.SessionTokenHeader) == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]interface{}{"id": "session_inactive", "code": 401}})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"id": "flow-id", "type": "api", "ui": map[string]interface{}{"nodes": []interface{}{}}})
+	})
+	mux.HandleFunc("/self-service/login", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("flow") != "flow-id" {
+			w.WriteHeader(http.StatusGone)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]interface{}{"id": "self_service_flow_expired"}})
+			return
+		}
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode login body: %v", err)
+		}
+		success := func() {
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"session":       map[string]interface{}{"id": "session-id", "identity": map[string]interface{}{"id": "identity-id", "schema_id": "default", "schema_url": "", "traits": map[string]string{}}},
+				"session_token": "ory_st_token",
+			})
+		}
+		switch {
+		case body["method"] == "password" && body["password"] == "secret":
+			success()
+		case body["method"] == "password":
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(flowWithMessages("flow-id", "choose_method", "password", "The provided credentials are invalid."))
+		case body["method"] == "code" && body["code"] == "":
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(flowWithMessages("flow-id", "sent_email", "code", "An email containing a code has been sent."))
+		case body["method"] == "code" && body["code"] == "123456":
+			success()
+		case body["method"] == "totp" && body["totp_code"] == "000000" && r.Header.Get(kratox.SessionTokenHeader) == "ory_st_token":
+			success()
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(flowWithMessages("flow-id", "choose_method", "code", "invalid code"))
+		}
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestLogin(t *testing.T) {
+	srv := fakeLogin(t)
+	defer srv.Close()
+	kratox.SetAddress(srv.URL, srv.URL)
+
+	tests := []struct {
+		name      string
+		req       kratox.LoginRequest
+		wantToken string
+		wantField string
+		wantState string
+		wantErr   bool
+	}{
+		{
+			name:      "password",
+			req:       kratox.LoginRequest{Method: kratox.LoginWithPassword, Identifier: "a@b.c", Password: "secret"},
+			wantToken: "ory_st_token",
+		},
+		{
+			name:      "wrong password",
+			req:       kratox.LoginRequest{Method: kratox.LoginWithPassword, Identifier: "a@b.c", Password: "wrong"},
+			wantField: "password",
+			wantState: "choose_method",
+			wantErr:   true,
+		},
+		{
+			name:      "code sent",
+			req:       kratox.LoginRequest{Method: kratox.LoginWithCode, Identifier: "a@b.c"},
+			wantField: "code",
+			wantState: "sent_email",
+			wantErr:   true,
+		},
+		{
+			name:      "code submitted on the same flow",
+			req:       kratox.LoginRequest{FlowID: "flow-id", Method: kratox.LoginWithCode, Code: "123456"},
+			wantToken: "ory_st_token",
+		},
+		{
+			name:      "second factor",
+			req:       kratox.LoginRequest{Method: kratox.LoginWithTOTP, Code: "000000", AAL: "aal2", SessionToken: "ory_st_token"},
+			wantToken: "ory_st_token",
+		},
+		{
+			name:    "aal2 without session",
+			req:     kratox.LoginRequest{Method: kratox.LoginWithTOTP, Code: "000000", AAL: "aal2"},
+			wantErr: true,
+		},
+		{
+			name:    "expired flow",
+			req:     kratox.LoginRequest{FlowID: "old", Method: kratox.LoginWithPassword},
+			wantErr: true,
+		},
+		{
+			name:    "unknown method",
+			req:     kratox.LoginRequest{Method: "magic"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := kratox.Kratox.Login(context.Background(), tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Login() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantField != "" {
+				var fe *kratox.FlowError
+				if !errors.As(err, &fe) {
+					t.Fatalf("Login() error = %v, want a FlowError", err)
+				}
+				if fe.FlowID != "flow-id" || fe.State != tt.wantState || len(fe.Fields[tt.wantField]) == 0 || fe.Form.Field(tt.wantField) == nil {
+					t.Errorf("Login() flow error = %+v", fe)
+				}
+			}
+			if err == nil && (got.GetSessionToken() != tt.wantToken || got.Session.Identity.Id != "identity-id") {
+				t.Errorf("Login() = %+v", got)
+			}
+		})
+	}
+}
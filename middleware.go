@@ -0,0 +1,169 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/w6d-io/x/errorx"
+	"github.com/w6d-io/x/logx"
+)
+
+// Middleware returns an http middleware which resolves the kratos session with AuthRequestFunc
+// and enforces the predicate bound to the request route in the policy.
+// Routes without predicate are served without resolving the session
+func Middleware(policy Policy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			predicate := policy.Lookup(r.Method+" "+r.URL.Path, r.URL.Path)
+			if predicate == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx := AuthRequestFunc(r.Context(), r)
+			if err := Authorize(ctx, predicate); err != nil {
+				WriteHTTPError(w, err)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// UnaryServerInterceptor returns a gRPC interceptor which resolves the kratos session with AuthGRPCFunc
+// and enforces the predicate bound to the called method in the policy, the methods without predicate
+// are served without resolving the session
+func UnaryServerInterceptor(policy Policy) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		predicate := policy.Lookup(info.FullMethod)
+		if predicate == nil {
+			return handler(ctx, req)
+		}
+		ctx = AuthGRPCFunc(ctx)
+		if err := Authorize(ctx, predicate); err != nil {
+			return nil, GRPCError(err)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a gRPC stream interceptor which resolves the kratos session with AuthGRPCFunc
+// and enforces the predicate bound to the called method in the policy, the methods without predicate
+// are served without resolving the session
+func StreamServerInterceptor(policy Policy) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		predicate := policy.Lookup(info.FullMethod)
+		if predicate == nil {
+			return handler(srv, ss)
+		}
+		ctx := AuthGRPCFunc(ss.Context())
+		if err := Authorize(ctx, predicate); err != nil {
+			return GRPCError(err)
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// WriteHTTPError writes the error as a json body with its status code. A *PrivilegedSessionError
+// is written with the forbidden status and the login url to send browsers to, a *FlowError with
+// the status code of the kratos response
+func WriteHTTPError(w http.ResponseWriter, err error) {
+	e, redirect := httpError(err)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.StatusCode)
+	_ = json.NewEncoder(w).Encode(struct {
+		Code              string `json:"code,omitempty"`
+		Message           string `json:"message"`
+		RedirectBrowserTo string `json:"redirect_browser_to,omitempty"`
+	}{Code: e.Code, Message: e.Message, RedirectBrowserTo: redirect})
+}
+
+// GRPCError converts the error into a gRPC status error according to its http status code
+func GRPCError(err error) error {
+	e, _ := httpError(err)
+	code := codes.Internal
+	switch e.StatusCode {
+	case http.StatusBadRequest:
+		code = codes.InvalidArgument
+	case http.StatusUnauthorized:
+		code = codes.Unauthenticated
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusGone:
+		code = codes.FailedPrecondition
+	case http.StatusTooManyRequests:
+		code = codes.ResourceExhausted
+	case http.StatusServiceUnavailable, http.StatusBadGateway:
+		code = codes.Unavailable
+	case http.StatusGatewayTimeout:
+		code = codes.DeadlineExceeded
+	}
+	return status.Error(code, e.Message)
+}
+
+// httpError returns the status code, code and message of the error, with the url to send browsers
+// to when the session has to be refreshed. The errors of unknown type are logged and reported as
+// internal errors without their text
+func httpError(err error) (*errorx.Error, string) {
+	var privileged *PrivilegedSessionError
+	if errors.As(err, &privileged) {
+		return &errorx.Error{
+			StatusCode: http.StatusForbidden,
+			Code:       sessionRefreshRequired,
+			Message:    privileged.Error(),
+		}, privileged.RedirectBrowserTo
+	}
+	var flowErr *FlowError
+	if errors.As(err, &flowErr) {
+		statusCode := flowErr.StatusCode
+		if statusCode == 0 {
+			statusCode = http.StatusBadRequest
+		}
+		return &errorx.Error{StatusCode: statusCode, Code: "flow_rejected", Message: flowErr.Error()}, ""
+	}
+	var e *errorx.Error
+	if !errors.As(err, &e) {
+		logx.WithName(context.Background(), "httpError").Error(err, "internal error")
+		return &errorx.Error{
+			StatusCode: http.StatusInternalServerError,
+			Code:       "internal_error",
+			Message:    http.StatusText(http.StatusInternalServerError),
+		}, ""
+	}
+	if e.StatusCode == 0 {
+		return &errorx.Error{StatusCode: http.StatusInternalServerError, Code: e.Code, Message: e.Message}, ""
+	}
+	return e, ""
+}
+
+// serverStream overrides the context of the wrapped stream
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
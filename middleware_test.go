@@ -0,0 +1,210 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	client "github.com/ory/kratos-client-go"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/w6d-io/kratox"
+	"github.com/w6d-io/x/errorx"
+)
+
+// countingKratos counts the session lookups
+type countingKratos struct {
+	kratosMock
+	calls *int32
+}
+
+func (k countingKratos) GetSessionFromHTTP(ctx context.Context, r *http.Request) (*client.Session, error) {
+	atomic.AddInt32(k.calls, 1)
+	return k.kratosMock.GetSessionFromHTTP(ctx, r)
+}
+
+func (k countingKratos) GetSessionFromGRPCCtx(ctx context.Context) (*client.Session, error) {
+	atomic.AddInt32(k.calls, 1)
+	return k.kratosMock.GetSessionFromGRPCCtx(ctx)
+}
+
+func TestMiddleware(t *testing.T) {
+	policy := kratox.Policy{
+		"/admin/*": kratox.HasRole("admin"),
+		"/me":      kratox.Authenticated(),
+	}
+	tests := []struct {
+		name      string
+		path      string
+		cookie    bool
+		mock      kratosMock
+		want      int
+		wantCalls int32
+	}{
+		{name: "public route", path: "/health", cookie: true, mock: kratosMock{behaviour: "ok"}, want: http.StatusOK},
+		{name: "no cookie", path: "/me", want: http.StatusUnauthorized},
+		{name: "kratos failure", path: "/me", cookie: true, mock: kratosMock{behaviour: "ko"}, want: http.StatusUnauthorized, wantCalls: 1},
+		{name: "authenticated", path: "/me", cookie: true, mock: kratosMock{behaviour: "ok"}, want: http.StatusOK, wantCalls: 1},
+		{name: "forbidden", path: "/admin/users", cookie: true, mock: kratosMock{behaviour: "ok"}, want: http.StatusForbidden, wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			kratox.Kratox = countingKratos{kratosMock: tt.mock, calls: &calls}
+			h := kratox.Middleware(policy)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.cookie {
+				req.AddCookie(&http.Cookie{Name: kratox.CookieName, Value: "test"})
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("Middleware() status = %v, want %v", rec.Code, tt.want)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("Middleware() resolved the session %d times, want %d", calls, tt.wantCalls)
+			}
+			if strings.Contains(rec.Body.String(), "admin") {
+				t.Errorf("Middleware() body exposes the policy: %s", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	policy := kratox.Policy{
+		"/pkg.Service/Admin": kratox.HasRole("admin"),
+		"/pkg.Service/Me":    kratox.Authenticated(),
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(kratox.CookieName, "test"))
+	tests := []struct {
+		name      string
+		method    string
+		mock      kratosMock
+		want      codes.Code
+		wantCalls int32
+	}{
+		{name: "public method", method: "/pkg.Service/Health", mock: kratosMock{behaviour: "ok"}, want: codes.OK},
+		{name: "no session", method: "/pkg.Service/Me", mock: kratosMock{behaviour: "ko"}, want: codes.Unauthenticated, wantCalls: 1},
+		{name: "authenticated", method: "/pkg.Service/Me", mock: kratosMock{behaviour: "ok"}, want: codes.OK, wantCalls: 1},
+		{name: "forbidden", method: "/pkg.Service/Admin", mock: kratosMock{behaviour: "ok"}, want: codes.PermissionDenied, wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			kratox.Kratox = countingKratos{kratosMock: tt.mock, calls: &calls}
+			interceptor := kratox.UnaryServerInterceptor(policy)
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+				if tt.wantCalls > 0 && kratox.GetCookieFromCtx(ctx) != "test" {
+					t.Errorf("cookie not recorded into context")
+				}
+				return nil, nil
+			})
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("UnaryServerInterceptor() code = %v, want %v", got, tt.want)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("UnaryServerInterceptor() resolved the session %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestWriteHTTPError(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		wantStatus   int
+		wantCode     string
+		wantRedirect string
+		wantGRPC     codes.Code
+	}{
+		{
+			name:       "unknown error",
+			err:        errors.New("keto: dial tcp 10.0.0.1:4466"),
+			wantStatus: http.StatusInternalServerError,
+			wantCode:   "internal_error",
+			wantGRPC:   codes.Internal,
+		},
+		{
+			name:       "http error",
+			err:        errorx.NewHTTP(errors.New("boom"), http.StatusNotFound, "not found"),
+			wantStatus: http.StatusNotFound,
+			wantGRPC:   codes.NotFound,
+		},
+		{
+			name:         "privileged session required",
+			err:          &kratox.PrivilegedSessionError{RedirectBrowserTo: "http://kratos/self-service/login/browser?refresh=true"},
+			wantStatus:   http.StatusForbidden,
+			wantCode:     "session_refresh_required",
+			wantRedirect: "http://kratos/self-service/login/browser?refresh=true",
+			wantGRPC:     codes.PermissionDenied,
+		},
+		{
+			name:       "flow rejected",
+			err:        &kratox.FlowError{StatusCode: http.StatusBadRequest, FlowID: "flow-id"},
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "flow_rejected",
+			wantGRPC:   codes.InvalidArgument,
+		},
+		{
+			name:       "flow gone",
+			err:        &kratox.FlowError{StatusCode: http.StatusGone, FlowID: "flow-id"},
+			wantStatus: http.StatusGone,
+			wantCode:   "flow_rejected",
+			wantGRPC:   codes.FailedPrecondition,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			kratox.WriteHTTPError(rec, tt.err)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("WriteHTTPError() status = %v, want %v", rec.Code, tt.wantStatus)
+			}
+			var body struct {
+				Code              string `json:"code"`
+				Message           string `json:"message"`
+				RedirectBrowserTo string `json:"redirect_browser_to"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("decode body error = %v", err)
+			}
+			if body.Code != tt.wantCode || body.RedirectBrowserTo != tt.wantRedirect {
+				t.Errorf("WriteHTTPError() body = %+v, want code %q and redirect %q", body, tt.wantCode, tt.wantRedirect)
+			}
+			if strings.Contains(body.Message, "10.0.0.1") {
+				t.Errorf("WriteHTTPError() message exposes the error: %s", body.Message)
+			}
+			if got := status.Code(kratox.GRPCError(tt.err)); got != tt.wantGRPC {
+				t.Errorf("GRPCError() code = %v, want %v", got, tt.wantGRPC)
+			}
+		})
+	}
+}
@@ -0,0 +1,276 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	client "github.com/ory/kratos-client-go"
+
+	"github.com/w6d-io/x/errorx"
+	"github.com/w6d-io/x/logx"
+)
+
+// Predicate is an authorization rule evaluated against the session identity
+type Predicate interface {
+	// Eval reports whether the session satisfies the rule
+	Eval(context.Context, *client.Session) (bool, error)
+	// String describes the rule, it is logged when the rule is not satisfied
+	String() string
+}
+
+// Policy binds predicates to routes or gRPC methods.
+// A key is either an HTTP path ("/admin"), an HTTP method and path ("DELETE /users")
+// or a gRPC full method ("/pkg.Service/Method"). A key ending with "*" matches
+// every route with this prefix, the longest prefix wins.
+type Policy map[string]Predicate
+
+type predicateFunc struct {
+	name string
+	fn   func(context.Context, *client.Session) (bool, error)
+}
+
+func (p *predicateFunc) Eval(ctx context.Context, s *client.Session) (bool, error) {
+	return p.fn(ctx, s)
+}
+
+func (p *predicateFunc) String() string {
+	return p.name
+}
+
+// NewPredicate builds a predicate from a function, name is used to describe the rule
+func NewPredicate(name string, fn func(context.Context, *client.Session) (bool, error)) Predicate {
+	return &predicateFunc{name: name, fn: fn}
+}
+
+// JSONPathEquals is satisfied when the value at path in the identity equals value.
+// The path is a dot separated list of fields rooted at the identity
+// (ex: "traits.email", "metadata_public.role", "metadata_public.roles.0")
+func JSONPathEquals(path string, value interface{}) Predicate {
+	return NewPredicate(fmt.Sprintf("%s == %v", path, value), func(_ context.Context, s *client.Session) (bool, error) {
+		got, ok, err := lookupIdentityPath(s, path)
+		if err != nil || !ok {
+			return false, err
+		}
+		want, err := normalizeJSON(value)
+		if err != nil {
+			return false, err
+		}
+		return reflect.DeepEqual(got, want), nil
+	})
+}
+
+// Contains is satisfied when the value at path in the identity is a list holding value
+// or a string containing it
+func Contains(path string, value interface{}) Predicate {
+	return NewPredicate(fmt.Sprintf("%s contains %v", path, value), func(_ context.Context, s *client.Session) (bool, error) {
+		got, ok, err := lookupIdentityPath(s, path)
+		if err != nil || !ok {
+			return false, err
+		}
+		want, err := normalizeJSON(value)
+		if err != nil {
+			return false, err
+		}
+		switch v := got.(type) {
+		case []interface{}:
+			for _, item := range v {
+				if reflect.DeepEqual(item, want) {
+					return true, nil
+				}
+			}
+		case string:
+			if w, ok := want.(string); ok {
+				return strings.Contains(v, w), nil
+			}
+		}
+		return false, nil
+	})
+}
+
+// HasRole is satisfied when the role is listed into metadata_public.roles
+func HasRole(role string) Predicate {
+	return Contains("metadata_public.roles", role)
+}
+
+// SchemaIs is satisfied when the identity uses the schema id
+func SchemaIs(id string) Predicate {
+	return NewPredicate("schema_id == "+id, func(_ context.Context, s *client.Session) (bool, error) {
+		return s.Identity.SchemaId == id, nil
+	})
+}
+
+// Authenticated is satisfied by any active session
+func Authenticated() Predicate {
+	return NewPredicate("authenticated", func(_ context.Context, s *client.Session) (bool, error) {
+		return s.GetActive(), nil
+	})
+}
+
+// And is satisfied when all the predicates are
+func And(predicates ...Predicate) Predicate {
+	return NewPredicate(join("and", predicates), func(ctx context.Context, s *client.Session) (bool, error) {
+		for _, p := range predicates {
+			ok, err := p.Eval(ctx, s)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+	})
+}
+
+// Or is satisfied when at least one of the predicates is
+func Or(predicates ...Predicate) Predicate {
+	return NewPredicate(join("or", predicates), func(ctx context.Context, s *client.Session) (bool, error) {
+		for _, p := range predicates {
+			ok, err := p.Eval(ctx, s)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+}
+
+// Not is satisfied when the predicate is not
+func Not(predicate Predicate) Predicate {
+	return NewPredicate("not ("+predicate.String()+")", func(ctx context.Context, s *client.Session) (bool, error) {
+		ok, err := predicate.Eval(ctx, s)
+		if err != nil {
+			return false, err
+		}
+		return !ok, nil
+	})
+}
+
+// Authorize evaluates the predicate against the session stored into the context.
+// It returns a StatusUnauthorized error when there is no session and a StatusForbidden
+// error when the predicate is not satisfied
+func Authorize(ctx context.Context, predicate Predicate) error {
+	log := logx.WithName(ctx, "Authorize")
+	if predicate == nil {
+		return nil
+	}
+	if err := GetSession(ctx); err != nil {
+		return err
+	}
+	sess, _ := GetSessionFromCtx(ctx)
+	ok, err := predicate.Eval(ctx, sess)
+	if err != nil {
+		log.Error(err, "evaluate predicate failed", "predicate", predicate.String())
+		return &errorx.Error{
+			Cause:      err,
+			StatusCode: http.StatusInternalServerError,
+			Code:       "auth_policy_failed",
+			Message:    "Authorization check failed",
+		}
+	}
+	if !ok {
+		log.V(1).Info("permission denied", "identity", sess.Identity.Id, "predicate", predicate.String())
+		return &errorx.Error{
+			StatusCode: http.StatusForbidden,
+			Code:       "auth_forbidden",
+			Message:    http.StatusText(http.StatusForbidden),
+		}
+	}
+	return nil
+}
+
+// Lookup returns the predicate bound to the first key matching, nil if none
+func (p Policy) Lookup(keys ...string) Predicate {
+	for _, key := range keys {
+		if predicate, ok := p[key]; ok {
+			return predicate
+		}
+	}
+	var prefixes []string
+	for k := range p {
+		if strings.HasSuffix(k, "*") {
+			prefixes = append(prefixes, k)
+		}
+	}
+	sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+	for _, prefix := range prefixes {
+		for _, key := range keys {
+			if strings.HasPrefix(key, strings.TrimSuffix(prefix, "*")) {
+				return p[prefix]
+			}
+		}
+	}
+	return nil
+}
+
+func join(op string, predicates []Predicate) string {
+	names := make([]string, 0, len(predicates))
+	for _, p := range predicates {
+		names = append(names, "("+p.String()+")")
+	}
+	return strings.Join(names, " "+op+" ")
+}
+
+// lookupIdentityPath returns the value at path from the session identity
+func lookupIdentityPath(s *client.Session, path string) (interface{}, bool, error) {
+	if s == nil {
+		return nil, false, nil
+	}
+	current, err := normalizeJSON(s.Identity)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, field := range strings.Split(strings.TrimPrefix(path, "$."), ".") {
+		switch v := current.(type) {
+		case map[string]interface{}:
+			next, ok := v[field]
+			if !ok {
+				return nil, false, nil
+			}
+			current = next
+		case []interface{}:
+			i, err := strconv.Atoi(field)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false, nil
+			}
+			current = v[i]
+		default:
+			return nil, false, nil
+		}
+	}
+	return current, true, nil
+}
+
+// normalizeJSON converts a value into its generic json representation
+func normalizeJSON(value interface{}) (interface{}, error) {
+	d, err := json.Marshal(value)
+	if err != nil {
+		return nil, errorx.Wrap(err, "marshal value failed")
+	}
+	var out interface{}
+	if err := json.Unmarshal(d, &out); err != nil {
+		return nil, errorx.Wrap(err, "unmarshal value failed")
+	}
+	return out, nil
+}
@@ -0,0 +1,228 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	client "github.com/ory/kratos-client-go"
+	"k8s.io/utils/pointer"
+
+	"github.com/w6d-io/x/errorx"
+
+	"github.com/w6d-io/kratox"
+)
+
+var admin = &client.Session{
+	Active: pointer.Bool(true),
+	Identity: client.Identity{
+		Id:       "admin",
+		SchemaId: "employee",
+		Traits: map[string]interface{}{
+			"email": "admin@example.com",
+			"level": 3,
+		},
+		MetadataPublic: map[string]interface{}{
+			"roles": []string{"admin", "user"},
+		},
+	},
+}
+
+func TestPredicates(t *testing.T) {
+	tests := []struct {
+		name      string
+		predicate kratox.Predicate
+		session   *client.Session
+		want      bool
+	}{
+		{
+			name:      "trait equals",
+			predicate: kratox.JSONPathEquals("traits.email", "admin@example.com"),
+			session:   admin,
+			want:      true,
+		},
+		{
+			name:      "trait equals with number",
+			predicate: kratox.JSONPathEquals("traits.level", 3),
+			session:   admin,
+			want:      true,
+		},
+		{
+			name:      "trait differs",
+			predicate: kratox.JSONPathEquals("traits.email", "user@example.com"),
+			session:   admin,
+			want:      false,
+		},
+		{
+			name:      "missing path",
+			predicate: kratox.JSONPathEquals("metadata_public.tenant", "w6d"),
+			session:   admin,
+			want:      false,
+		},
+		{
+			name:      "list index",
+			predicate: kratox.JSONPathEquals("$.metadata_public.roles.1", "user"),
+			session:   admin,
+			want:      true,
+		},
+		{
+			name:      "has role",
+			predicate: kratox.HasRole("admin"),
+			session:   admin,
+			want:      true,
+		},
+		{
+			name:      "has not role",
+			predicate: kratox.HasRole("admin"),
+			session:   session,
+			want:      false,
+		},
+		{
+			name:      "string contains",
+			predicate: kratox.Contains("traits.email", "@example.com"),
+			session:   admin,
+			want:      true,
+		},
+		{
+			name:      "schema",
+			predicate: kratox.SchemaIs("employee"),
+			session:   admin,
+			want:      true,
+		},
+		{
+			name:      "and",
+			predicate: kratox.And(kratox.SchemaIs("employee"), kratox.HasRole("root")),
+			session:   admin,
+			want:      false,
+		},
+		{
+			name:      "or",
+			predicate: kratox.Or(kratox.HasRole("root"), kratox.HasRole("admin")),
+			session:   admin,
+			want:      true,
+		},
+		{
+			name:      "not",
+			predicate: kratox.Not(kratox.HasRole("admin")),
+			session:   session,
+			want:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.predicate.Eval(context.Background(), tt.session)
+			if err != nil {
+				t.Errorf("Eval() error = %v", err)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Eval() %s = %v, want %v", tt.predicate, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		predicate kratox.Predicate
+		want      int
+	}{
+		{
+			name:      "no predicate",
+			ctx:       context.Background(),
+			predicate: nil,
+			want:      0,
+		},
+		{
+			name:      "no session",
+			ctx:       context.Background(),
+			predicate: kratox.Authenticated(),
+			want:      http.StatusUnauthorized,
+		},
+		{
+			name:      "forbidden",
+			ctx:       kratox.SetSessionInCtx(context.Background(), session),
+			predicate: kratox.HasRole("admin"),
+			want:      http.StatusForbidden,
+		},
+		{
+			name:      "allowed",
+			ctx:       kratox.SetSessionInCtx(context.Background(), admin),
+			predicate: kratox.HasRole("admin"),
+			want:      0,
+		},
+		{
+			name: "predicate failure",
+			ctx:  kratox.SetSessionInCtx(context.Background(), admin),
+			predicate: kratox.NewPredicate("broken", func(context.Context, *client.Session) (bool, error) {
+				return false, errors.New("broken")
+			}),
+			want: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := kratox.Authorize(tt.ctx, tt.predicate)
+			if tt.want == 0 {
+				if err != nil {
+					t.Errorf("Authorize() error = %v", err)
+				}
+				return
+			}
+			var e *errorx.Error
+			if !errors.As(err, &e) || e.StatusCode != tt.want {
+				t.Errorf("Authorize() error = %v, want status %d", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicy_Lookup(t *testing.T) {
+	admin := kratox.HasRole("admin")
+	root := kratox.HasRole("root")
+	auth := kratox.Authenticated()
+	policy := kratox.Policy{
+		"/admin/*":           admin,
+		"/admin/root/*":      root,
+		"DELETE /users":      admin,
+		"/users":             auth,
+		"/pkg.Service/Write": admin,
+	}
+	tests := []struct {
+		name string
+		keys []string
+		want kratox.Predicate
+	}{
+		{name: "exact path", keys: []string{"GET /users", "/users"}, want: auth},
+		{name: "method and path", keys: []string{"DELETE /users", "/users"}, want: admin},
+		{name: "prefix", keys: []string{"GET /admin/users", "/admin/users"}, want: admin},
+		{name: "longest prefix", keys: []string{"GET /admin/root/x", "/admin/root/x"}, want: root},
+		{name: "grpc method", keys: []string{"/pkg.Service/Write"}, want: admin},
+		{name: "no rule", keys: []string{"/pkg.Service/Read"}, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.Lookup(tt.keys...); got != tt.want {
+				t.Errorf("Lookup() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-jose/go-jose/v3/jwt"
+
+	"github.com/w6d-io/x/errorx"
+	"github.com/w6d-io/x/logx"
+)
+
+// IDToken holds the claims of the id token returned by the provider at sign up
+type IDToken struct {
+	Issuer        string    `json:"iss"`
+	Subject       string    `json:"sub"`
+	Audience      []string  `json:"aud"`
+	Expiry        time.Time `json:"exp"`
+	IssuedAt      time.Time `json:"iat"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	Name          string    `json:"name"`
+	GivenName     string    `json:"given_name"`
+	FamilyName    string    `json:"family_name"`
+	Picture       string    `json:"picture"`
+	Locale        string    `json:"locale"`
+	Groups        []string  `json:"groups"`
+	// Extra holds the other claims as decoded from json
+	Extra map[string]interface{} `json:"extra"`
+}
+
+// idTokenClaims is the json form of the standard claims
+type idTokenClaims struct {
+	jwt.Claims
+	Email         string      `json:"email"`
+	EmailVerified interface{} `json:"email_verified"`
+	Name          string      `json:"name"`
+	GivenName     string      `json:"given_name"`
+	FamilyName    string      `json:"family_name"`
+	Picture       string      `json:"picture"`
+	Locale        string      `json:"locale"`
+	Groups        []string    `json:"groups"`
+}
+
+// standardClaims are the claims not reported in Extra
+var standardClaims = []string{"iss", "sub", "aud", "exp", "iat", "nbf", "jti", "email", "email_verified",
+	"name", "given_name", "family_name", "picture", "locale", "groups"}
+
+var (
+	// ErrProviderNotFound is returned when the provider is not linked with the identity
+	ErrProviderNotFound = errorx.New("provider not found")
+
+	errNoIDToken        = errorx.New("no id token for the provider")
+	errIncompleteConfig = errorx.New("issuer and client id of the provider required")
+)
+
+// ProviderConfig is the config of an oidc provider used to verify its id tokens,
+// it mirrors the provider config of kratos
+type ProviderConfig struct {
+	// Issuer expected in the iss claim, the issuer_url of the kratos provider
+	Issuer string `json:"issuer" mapstructure:"issuer"`
+	// ClientID expected in the aud claim, the client_id of the kratos provider
+	ClientID string `json:"clientId" mapstructure:"clientId"`
+	// Keys is the key set of the provider
+	Keys *JWKS `json:"keys" mapstructure:"keys"`
+}
+
+// IDTokenClaims decodes the claims of the id token returned by the provider at sign up.
+// The signature is not verified, the token was received by kratos from the provider
+func (p Provider) IDTokenClaims() (map[string]interface{}, error) {
+	if p.TokenID == "" {
+		return nil, errNoIDToken
+	}
+	tok, err := jwt.ParseSigned(p.TokenID)
+	if err != nil {
+		return nil, errorx.Wrap(err, "parse id token failed")
+	}
+	claims := map[string]interface{}{}
+	if err := tok.UnsafeClaimsWithoutVerification(&claims); err != nil {
+		return nil, errorx.Wrap(err, "decode id token failed")
+	}
+	return claims, nil
+}
+
+// DecodeIDToken decodes the id token returned by the provider at sign up into its typed claims.
+// The signature is not verified, see VerifyIDToken
+func (p Provider) DecodeIDToken() (*IDToken, error) {
+	claims, err := p.IDTokenClaims()
+	if err != nil {
+		return nil, err
+	}
+	return newIDToken(claims)
+}
+
+// VerifyIDToken checks the signature of the id token against the key set of the provider, its issuer
+// and its audience against the provider config, then decodes its typed claims. The validity period
+// is not checked, the token was issued at sign up and is usually expired
+func (p Provider) VerifyIDToken(ctx context.Context, cfg ProviderConfig) (*IDToken, error) {
+	log := logx.WithName(ctx, "VerifyIDToken")
+	if p.TokenID == "" {
+		log.Error(errNoIDToken, "get id token failed", "provider", p.Provider)
+		return nil, errorx.NewHTTP(errNoIDToken, http.StatusNotFound, "no id token for the provider")
+	}
+	if cfg.Keys == nil {
+		return nil, errorx.NewHTTP(errNoJWKS, http.StatusInternalServerError, "no key to verify token")
+	}
+	if cfg.Issuer == "" || cfg.ClientID == "" {
+		log.Error(errIncompleteConfig, "get provider config failed", "provider", p.Provider)
+		return nil, errorx.NewHTTP(errIncompleteConfig, http.StatusInternalServerError, "provider config incomplete")
+	}
+	tok, err := jwt.ParseSigned(p.TokenID)
+	if err != nil {
+		log.Error(err, "parse id token failed", "provider", p.Provider)
+		return nil, errorx.NewHTTP(err, http.StatusUnauthorized, "invalid token")
+	}
+	claims := map[string]interface{}{}
+	if err := cfg.Keys.verify(ctx, tok, &claims); err != nil {
+		return nil, err
+	}
+	t, err := newIDToken(claims)
+	if err != nil {
+		log.Error(err, "decode id token failed", "provider", p.Provider)
+		return nil, errorx.NewHTTP(err, http.StatusUnauthorized, "invalid id token claims")
+	}
+	// the claims holding the validity period are left out
+	std := jwt.Claims{Issuer: t.Issuer, Audience: t.Audience}
+	if err := std.Validate(jwt.Expected{Issuer: cfg.Issuer, Audience: jwt.Audience{cfg.ClientID}}); err != nil {
+		log.Error(err, "validate id token claims failed", "provider", p.Provider, "iss", t.Issuer, "aud", t.Audience)
+		return nil, errorx.NewHTTP(err, http.StatusUnauthorized, "invalid id token claims")
+	}
+	return t, nil
+}
+
+// newIDToken maps the claims into the id token
+func newIDToken(claims map[string]interface{}) (*IDToken, error) {
+	d, err := json.Marshal(claims)
+	if err != nil {
+		return nil, errorx.Wrap(err, "encode id token claims failed")
+	}
+	var c idTokenClaims
+	if err := json.Unmarshal(d, &c); err != nil {
+		return nil, errorx.Wrap(err, "decode id token claims failed")
+	}
+	t := &IDToken{
+		Issuer:     c.Issuer,
+		Subject:    c.Subject,
+		Audience:   c.Audience,
+		Email:      c.Email,
+		Name:       c.Name,
+		GivenName:  c.GivenName,
+		FamilyName: c.FamilyName,
+		Picture:    c.Picture,
+		Locale:     c.Locale,
+		Groups:     c.Groups,
+		Extra:      map[string]interface{}{},
+	}
+	// some providers send email_verified as a string
+	switch v := c.EmailVerified.(type) {
+	case bool:
+		t.EmailVerified = v
+	case string:
+		t.EmailVerified = v == "true"
+	}
+	if c.Expiry != nil {
+		t.Expiry = c.Expiry.Time()
+	}
+	if c.IssuedAt != nil {
+		t.IssuedAt = c.IssuedAt.Time()
+	}
+	for k, v := range claims {
+		t.Extra[k] = v
+	}
+	for _, k := range standardClaims {
+		delete(t.Extra, k)
+	}
+	return t, nil
+}
@@ -0,0 +1,199 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+
+	"github.com/w6d-io/kratox"
+)
+
+// fakeOIDCIdentity serves the identity of the session with its oidc credentials
+func fakeOIDCIdentity(t *testing.T, providers []map[string]interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/admin/identities/"+session.Identity.Id {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"id": session.Identity.Id, "schema_id": "default", "schema_url": "", "traits": map[string]string{},
+			"credentials": map[string]interface{}{
+				"oidc": map[string]interface{}{
+					"type":        "oidc",
+					"identifiers": []string{},
+					"config":      map[string]interface{}{"providers": providers},
+				},
+			},
+		})
+	}))
+}
+
+func TestGetProviderAccounts(t *testing.T) {
+	signer, _ := newSigner(t, "kid")
+	idToken := sign(t, signer, jwt.Claims{Subject: "sub-1", Issuer: "https://accounts.google.com"}, map[string]interface{}{"email": "a@b.c"})
+	srv := fakeOIDCIdentity(t, []map[string]interface{}{
+		{"provider": "google", "subject": "sub-1", "organization": "w6d", "initial_id_token": idToken},
+		{"provider": "google", "subject": "sub-2"},
+		{"provider": "github", "subject": "sub-3"},
+	})
+	defer srv.Close()
+	kratox.SetAddress(srv.URL, srv.URL)
+	ctx := kratox.SetSessionInCtx(context.Background(), session)
+
+	tests := []struct {
+		name         string
+		provider     string
+		wantSubjects []string
+		wantErr      error
+	}{
+		{name: "several accounts", provider: "google", wantSubjects: []string{"sub-1", "sub-2"}},
+		{name: "one account", provider: "github", wantSubjects: []string{"sub-3"}},
+		{name: "not linked", provider: "gitlab", wantErr: kratox.ErrProviderNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := kratox.Kratox.GetProviderAccounts(ctx, tt.provider)
+			first, firstErr := kratox.Kratox.GetToken(ctx, tt.provider)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) || !errors.Is(firstErr, tt.wantErr) {
+					t.Errorf("errors = %v, %v, want %v", err, firstErr, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || firstErr != nil {
+				t.Fatalf("unexpected errors %v, %v", err, firstErr)
+			}
+			if len(got) != len(tt.wantSubjects) || first.Subject != tt.wantSubjects[0] {
+				t.Fatalf("GetProviderAccounts() = %+v, GetToken() = %+v", got, first)
+			}
+			for i, p := range got {
+				if p.Subject != tt.wantSubjects[i] {
+					t.Errorf("account %d subject = %s, want %s", i, p.Subject, tt.wantSubjects[i])
+				}
+			}
+		})
+	}
+
+	google, err := kratox.Kratox.GetToken(ctx, "google")
+	if err != nil {
+		t.Fatalf("GetToken() error = %v", err)
+	}
+	if google.Organization != "w6d" {
+		t.Errorf("Organization = %s", google.Organization)
+	}
+	claims, err := google.IDTokenClaims()
+	if err != nil || claims["email"] != "a@b.c" || claims["sub"] != "sub-1" {
+		t.Errorf("IDTokenClaims() = %v, %v", claims, err)
+	}
+	if _, err := (kratox.Provider{}).IDTokenClaims(); err == nil {
+		t.Error("IDTokenClaims() expected an error without id token")
+	}
+}
+
+func TestProvider_VerifyIDToken(t *testing.T) {
+	signer, keys := newSigner(t, "google")
+	_, otherKeys := newSigner(t, "google")
+	expired := time.Now().Add(-24 * time.Hour)
+	idToken := sign(t, signer,
+		jwt.Claims{Subject: "sub-1", Issuer: "https://accounts.google.com", Audience: jwt.Audience{"client"}, Expiry: jwt.NewNumericDate(expired)},
+		map[string]interface{}{"email": "a@b.c", "email_verified": "true", "groups": []string{"dev", "ops"}, "picture": "http://pic", "hd": "w6d.io"})
+	file := func(set jose.JSONWebKeySet) string {
+		d, err := json.Marshal(set)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f := filepath.Join(t.TempDir(), "jwks.json")
+		if err := os.WriteFile(f, d, 0600); err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+	want := &kratox.IDToken{
+		Issuer:        "https://accounts.google.com",
+		Subject:       "sub-1",
+		Audience:      []string{"client"},
+		Expiry:        time.Unix(expired.Unix(), 0),
+		Email:         "a@b.c",
+		EmailVerified: true,
+		Picture:       "http://pic",
+		Groups:        []string{"dev", "ops"},
+		Extra:         map[string]interface{}{"hd": "w6d.io"},
+	}
+
+	config := func(keys *kratox.JWKS) *kratox.ProviderConfig {
+		return &kratox.ProviderConfig{Issuer: "https://accounts.google.com", ClientID: "client", Keys: keys}
+	}
+
+	tests := []struct {
+		name     string
+		provider kratox.Provider
+		config   *kratox.ProviderConfig
+		wantErr  bool
+	}{
+		{name: "verified", provider: kratox.Provider{TokenID: idToken}, config: config(&kratox.JWKS{File: file(keys)})},
+		{name: "not verified", provider: kratox.Provider{TokenID: idToken}},
+		{name: "wrong keys", provider: kratox.Provider{TokenID: idToken}, config: config(&kratox.JWKS{File: file(otherKeys)}), wantErr: true},
+		{name: "no id token", provider: kratox.Provider{}, config: config(&kratox.JWKS{File: file(keys)}), wantErr: true},
+		{name: "malformed", provider: kratox.Provider{TokenID: "a.b.c"}, config: config(&kratox.JWKS{File: file(keys)}), wantErr: true},
+		{
+			name:     "wrong issuer",
+			provider: kratox.Provider{TokenID: idToken},
+			config:   &kratox.ProviderConfig{Issuer: "https://evil.example.com", ClientID: "client", Keys: &kratox.JWKS{File: file(keys)}},
+			wantErr:  true,
+		},
+		{
+			name:     "wrong audience",
+			provider: kratox.Provider{TokenID: idToken},
+			config:   &kratox.ProviderConfig{Issuer: "https://accounts.google.com", ClientID: "other-client", Keys: &kratox.JWKS{File: file(keys)}},
+			wantErr:  true,
+		},
+		{
+			name:     "incomplete config",
+			provider: kratox.Provider{TokenID: idToken},
+			config:   &kratox.ProviderConfig{Keys: &kratox.JWKS{File: file(keys)}},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *kratox.IDToken
+			var err error
+			if tt.config != nil {
+				got, err = tt.provider.VerifyIDToken(context.Background(), *tt.config)
+			} else {
+				got, err = tt.provider.DecodeIDToken()
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
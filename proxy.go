@@ -0,0 +1,176 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/w6d-io/x/logx"
+)
+
+var (
+	// proxiedPaths are the kratos public paths served by the proxy
+	proxiedPaths = []string{"/self-service/", "/sessions/", "/.well-known/ory/"}
+)
+
+// Proxy is an http.Handler forwarding the browser flows to the kratos public api, so the frontend
+// reaches kratos through the api host. The cookies set by kratos are rewritten for the api host
+// and the redirections to kratos are rewritten to the proxy
+type Proxy struct {
+	// Address of the kratos public api, as Conn.Address
+	Address string `json:"address" mapstructure:"address"`
+	// Prefix is the path the proxy is mounted on (ex: /.ory), it is stripped before forwarding
+	Prefix string `json:"prefix" mapstructure:"prefix"`
+	// CookieDomain replaces the domain of the cookies, they are bound to the api host when empty
+	CookieDomain string `json:"cookieDomain" mapstructure:"cookieDomain"`
+	// CookiePath replaces the path of the cookies when set
+	CookiePath string `json:"cookiePath" mapstructure:"cookiePath"`
+	// TrustForwardedHeaders reads the public host and scheme from the X-Forwarded-Host and
+	// X-Forwarded-Proto headers, set it only behind a proxy overwriting them
+	TrustForwardedHeaders bool `json:"trustForwardedHeaders" mapstructure:"trustForwardedHeaders"`
+}
+
+var _ http.Handler = &Proxy{}
+
+// NewProxy returns the proxy to the kratos public api address
+func NewProxy(address string) *Proxy {
+	return &Proxy{Address: address}
+}
+
+// ServeHTTP forwards the self-service, sessions and well-known requests to kratos, the other
+// paths, the paths out of the prefix and the paths holding dot segments are not found
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	log := logx.WithName(r.Context(), "Proxy")
+	trimmed, ok := trimPrefix(r.URL.Path, strings.TrimSuffix(p.Prefix, "/"))
+	kratosPath := path.Clean("/" + trimmed)
+	if !ok || (trimmed != kratosPath && trimmed != kratosPath+"/") || !proxied(kratosPath) {
+		http.NotFound(w, r)
+		return
+	}
+	target, err := Conn{Address: p.Address}.getKratosAddress()
+	if err != nil {
+		log.Error(err, "get kratos address failed")
+		http.Error(w, "fail to get kratos address", http.StatusBadGateway)
+		return
+	}
+	public := publicURL(r, p.Prefix, p.TrustForwardedHeaders)
+	rp := &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			req.URL.Scheme = target.Scheme
+			req.URL.Host = target.Host
+			req.URL.Path = strings.TrimSuffix(target.Path, "/") + kratosPath
+			req.URL.RawPath = ""
+			req.Host = target.Host
+			req.Header.Set("X-Forwarded-Host", public.Host)
+			req.Header.Set("X-Forwarded-Proto", public.Scheme)
+		},
+		ModifyResponse: func(rsp *http.Response) error {
+			p.rewriteCookies(rsp)
+			if location := rsp.Header.Get("Location"); location != "" {
+				rsp.Header.Set("Location", rewriteLocation(location, target, public))
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, req *http.Request, err error) {
+			log.Error(err, "proxy to kratos failed", "path", kratosPath)
+			w.WriteHeader(http.StatusBadGateway)
+		},
+	}
+	rp.ServeHTTP(w, r)
+}
+
+// rewriteCookies binds the cookies set by kratos to the api host
+func (p *Proxy) rewriteCookies(rsp *http.Response) {
+	cookies := rsp.Cookies()
+	if len(cookies) == 0 {
+		return
+	}
+	rsp.Header.Del("Set-Cookie")
+	for _, c := range cookies {
+		c.Domain = p.CookieDomain
+		if p.CookiePath != "" {
+			c.Path = p.CookiePath
+		}
+		rsp.Header.Add("Set-Cookie", c.String())
+	}
+}
+
+// rewriteLocation points the redirections to kratos to the proxy, the other ones are kept
+func rewriteLocation(location string, target, public *url.URL) string {
+	u, err := url.Parse(location)
+	if err != nil {
+		return location
+	}
+	if u.Host != "" && u.Host != target.Host {
+		return location
+	}
+	path := u.Path
+	if u.Host != "" {
+		path = "/" + strings.TrimPrefix(strings.TrimPrefix(path, strings.TrimSuffix(target.Path, "/")), "/")
+	}
+	if !proxied(path) {
+		return location
+	}
+	u.Scheme = public.Scheme
+	u.Host = public.Host
+	u.Path = strings.TrimSuffix(public.Path, "/") + path
+	u.RawPath = ""
+	return u.String()
+}
+
+// publicURL is the url of the proxy as seen by the browser, the forwarded headers are only read
+// when they are trusted
+func publicURL(r *http.Request, prefix string, trustForwarded bool) *url.URL {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	host := r.Host
+	if trustForwarded {
+		if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if h := r.Header.Get("X-Forwarded-Host"); h != "" {
+			host = h
+		}
+	}
+	return &url.URL{Scheme: scheme, Host: host, Path: strings.TrimSuffix(prefix, "/")}
+}
+
+// trimPrefix removes the prefix of the path, it reports false when the path is out of the prefix
+func trimPrefix(path, prefix string) (string, bool) {
+	if prefix == "" {
+		return path, true
+	}
+	if path != prefix && !strings.HasPrefix(path, prefix+"/") {
+		return "", false
+	}
+	return strings.TrimPrefix(path, prefix), true
+}
+
+// proxied reports whether the path is a proxied path or under one of them
+func proxied(path string) bool {
+	for _, p := range proxiedPaths {
+		if strings.HasPrefix(path, p) || path == strings.TrimSuffix(p, "/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/w6d-io/kratox"
+)
+
+func TestProxy(t *testing.T) {
+	var upstream *httptest.Server
+	upstream = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/self-service/login/browser":
+			http.SetCookie(w, &http.Cookie{Name: "csrf_token", Value: "csrf", Domain: "kratos.internal", Path: "/", HttpOnly: true})
+			http.Redirect(w, r, upstream.URL+"/self-service/login/browser?flow=flow-id", http.StatusSeeOther)
+		case "/self-service/logout/browser":
+			http.Redirect(w, r, "https://app.example.com/", http.StatusSeeOther)
+		case "/sessions/whoami", "/sessions":
+			w.Header().Set("X-Cookie", r.Header.Get("Cookie"))
+			w.WriteHeader(http.StatusOK)
+		default:
+			t.Errorf("unexpected upstream path %s", r.URL.Path)
+		}
+	}))
+	defer upstream.Close()
+
+	p := kratox.NewProxy(upstream.URL)
+	p.Prefix = "/.ory"
+	p.CookiePath = "/.ory"
+
+	tests := []struct {
+		name         string
+		path         string
+		forwarded    string
+		trust        bool
+		wantStatus   int
+		wantLocation string
+		wantCookie   string
+	}{
+		{
+			name:         "login redirect and cookies rewritten",
+			path:         "/.ory/self-service/login/browser",
+			wantStatus:   http.StatusSeeOther,
+			wantLocation: "http://api.example.com/.ory/self-service/login/browser?flow=flow-id",
+			wantCookie:   "csrf_token=csrf; Path=/.ory; HttpOnly",
+		},
+		{
+			name:         "forwarded host ignored",
+			path:         "/.ory/self-service/login/browser",
+			forwarded:    "evil.example.com",
+			wantStatus:   http.StatusSeeOther,
+			wantLocation: "http://api.example.com/.ory/self-service/login/browser?flow=flow-id",
+			wantCookie:   "csrf_token=csrf; Path=/.ory; HttpOnly",
+		},
+		{
+			name:         "forwarded host trusted",
+			path:         "/.ory/self-service/login/browser",
+			forwarded:    "public.example.com",
+			trust:        true,
+			wantStatus:   http.StatusSeeOther,
+			wantLocation: "https://public.example.com/.ory/self-service/login/browser?flow=flow-id",
+			wantCookie:   "csrf_token=csrf; Path=/.ory; HttpOnly",
+		},
+		{
+			name:       "dot segments",
+			path:       "/.ory/self-service/../admin/identities",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "dot segments back into the allow-list",
+			path:       "/.ory/self-service/../sessions/whoami",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:         "external redirect kept",
+			path:         "/.ory/self-service/logout/browser",
+			wantStatus:   http.StatusSeeOther,
+			wantLocation: "https://app.example.com/",
+		},
+		{
+			name:       "session",
+			path:       "/.ory/sessions/whoami",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "sessions list",
+			path:       "/.ory/sessions",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "out of prefix",
+			path:       "/self-service/login/browser",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "prefix not on a segment",
+			path:       "/.oryx/self-service/login/browser",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "admin path not proxied",
+			path:       "/.ory/admin/identities",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://api.example.com"+tt.path, nil)
+			req.AddCookie(&http.Cookie{Name: kratox.CookieName, Value: "session"})
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-Host", tt.forwarded)
+				req.Header.Set("X-Forwarded-Proto", "https")
+			}
+			p.TrustForwardedHeaders = tt.trust
+			w := httptest.NewRecorder()
+			p.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("Location = %s, want %s", got, tt.wantLocation)
+			}
+			if got := w.Header().Get("Set-Cookie"); got != tt.wantCookie {
+				t.Errorf("Set-Cookie = %s, want %s", got, tt.wantCookie)
+			}
+			if tt.path == "/.ory/sessions/whoami" && w.Header().Get("X-Cookie") != kratox.CookieName+"=session" {
+				t.Errorf("session cookie not forwarded: %s", w.Header().Get("X-Cookie"))
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	client "github.com/ory/kratos-client-go"
+
+	"github.com/w6d-io/x/errorx"
+	"github.com/w6d-io/x/logx"
+)
+
+var (
+	// ErrIdentityNotFound is returned when no identity matches the identifier
+	ErrIdentityNotFound = errorx.New("identity not found")
+)
+
+// CreateRecoveryLink creates a recovery link for the identity, valid for expiresIn.
+// The kratos default lifespan is used when expiresIn is zero
+// if kratos is unreachable or an other issues, return nil with statusCode of the call and error-go
+func (a auth) CreateRecoveryLink(ctx context.Context, identityID string, expiresIn time.Duration) (*client.RecoveryLinkForIdentity, error) {
+	log := logx.WithName(ctx, "CreateRecoveryLink")
+	api, err := a.adminAPI()
+	if err != nil {
+		return nil, err
+	}
+	body := client.NewCreateRecoveryLinkForIdentityBody(identityID)
+	if expiresIn > 0 {
+		body.SetExpiresIn(kratosDuration(expiresIn))
+	}
+	link, r, err := api.IdentityApi.CreateRecoveryLinkForIdentity(ctx).CreateRecoveryLinkForIdentityBody(*body).Execute()
+	if err != nil {
+		log.Error(err, "calling fail", "name", "CreateRecoveryLinkForIdentity", "response", r)
+		return nil, errorx.NewHTTP(err, statusOf(r, err), "fail to call kratos")
+	}
+	log.V(1).Info("recovery link created", "id", identityID)
+	return link, nil
+}
+
+// CreateRecoveryCode creates a recovery code for the identity, valid for expiresIn, along with
+// the link of the recovery flow where the code is entered.
+// The kratos default lifespan is used when expiresIn is zero
+// if kratos is unreachable or an other issues, return nil with statusCode of the call and error-go
+func (a auth) CreateRecoveryCode(ctx context.Context, identityID string, expiresIn time.Duration) (*client.RecoveryCodeForIdentity, error) {
+	log := logx.WithName(ctx, "CreateRecoveryCode")
+	api, err := a.adminAPI()
+	if err != nil {
+		return nil, err
+	}
+	body := client.NewCreateRecoveryCodeForIdentityBody(identityID)
+	if expiresIn > 0 {
+		body.SetExpiresIn(kratosDuration(expiresIn))
+	}
+	code, r, err := api.IdentityApi.CreateRecoveryCodeForIdentity(ctx).CreateRecoveryCodeForIdentityBody(*body).Execute()
+	if err != nil {
+		log.Error(err, "calling fail", "name", "CreateRecoveryCodeForIdentity", "response", r)
+		return nil, errorx.NewHTTP(err, statusOf(r, err), "fail to call kratos")
+	}
+	log.V(1).Info("recovery code created", "id", identityID)
+	return code, nil
+}
+
+// GetIdentityByIdentifier returns the identity holding the credential identifier, usually the email.
+// It returns ErrIdentityNotFound with the 404 status when there is none
+// if kratos is unreachable or an other issues, return nil with statusCode of the call and error-go
+func (a auth) GetIdentityByIdentifier(ctx context.Context, identifier string) (*client.Identity, error) {
+	log := logx.WithName(ctx, "GetIdentityByIdentifier")
+	api, err := a.adminAPI()
+	if err != nil {
+		return nil, err
+	}
+	identities, r, err := api.IdentityApi.ListIdentities(ctx).CredentialsIdentifier(identifier).Execute()
+	if err != nil {
+		log.Error(err, "calling fail", "name", "ListIdentities", "response", r)
+		return nil, errorx.NewHTTP(err, statusOf(r, err), "fail to call kratos")
+	}
+	if len(identities) == 0 {
+		log.V(1).Info("no identity for the identifier")
+		return nil, errorx.NewHTTP(ErrIdentityNotFound, http.StatusNotFound, "identity not found")
+	}
+	return &identities[0], nil
+}
+
+// CreateRecoveryLinkForEmail looks up the identity by its email then creates its recovery link
+func (a auth) CreateRecoveryLinkForEmail(ctx context.Context, email string, expiresIn time.Duration) (*client.RecoveryLinkForIdentity, error) {
+	i, err := a.GetIdentityByIdentifier(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	return a.CreateRecoveryLink(ctx, i.Id, expiresIn)
+}
+
+// CreateRecoveryCodeForEmail looks up the identity by its email then creates its recovery code
+func (a auth) CreateRecoveryCodeForEmail(ctx context.Context, email string, expiresIn time.Duration) (*client.RecoveryCodeForIdentity, error) {
+	i, err := a.GetIdentityByIdentifier(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	return a.CreateRecoveryCode(ctx, i.Id, expiresIn)
+}
+
+// kratosDuration formats the duration the way kratos expects it, in seconds
+func kratosDuration(d time.Duration) string {
+	s := int64((d + time.Second - 1) / time.Second)
+	return strconv.FormatInt(s, 10) + "s"
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/w6d-io/kratox"
+	"github.com/w6d-io/x/errorx"
+)
+
+func fakeRecovery(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin/identities", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		identities := []interface{}{}
+		if r.URL.Query().Get("credentials_identifier") == "a@b.c" {
+			identities = append(identities, map[string]interface{}{"id": "identity-id", "schema_id": "default", "schema_url": "", "traits": map[string]string{"email": "a@b.c"}})
+		}
+		_ = json.NewEncoder(w).Encode(identities)
+	})
+	recovery := func(w http.ResponseWriter, r *http.Request) map[string]interface{} {
+		w.Header().Set("Content-Type", "application/json")
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode recovery body: %v", err)
+		}
+		if body["identity_id"] != "identity-id" {
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]interface{}{"code": 404, "message": "not found"}})
+			return nil
+		}
+		return map[string]interface{}{
+			"recovery_link": "http://kratos/self-service/recovery?expires_in=" + body["expires_in"],
+			"expires_at":    time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+		}
+	}
+	mux.HandleFunc("/admin/recovery/link", func(w http.ResponseWriter, r *http.Request) {
+		if out := recovery(w, r); out != nil {
+			_ = json.NewEncoder(w).Encode(out)
+		}
+	})
+	mux.HandleFunc("/admin/recovery/code", func(w http.ResponseWriter, r *http.Request) {
+		if out := recovery(w, r); out != nil {
+			out["recovery_code"] = "123456"
+			_ = json.NewEncoder(w).Encode(out)
+		}
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestRecovery(t *testing.T) {
+	srv := fakeRecovery(t)
+	defer srv.Close()
+	kratox.SetAddress(srv.URL, srv.URL)
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		email      string
+		expiresIn  time.Duration
+		wantLink   string
+		wantStatus int
+	}{
+		{
+			name:      "link with expiry",
+			email:     "a@b.c",
+			expiresIn: 90 * time.Minute,
+			wantLink:  "http://kratos/self-service/recovery?expires_in=5400s",
+		},
+		{
+			name:     "default expiry",
+			email:    "a@b.c",
+			wantLink: "http://kratos/self-service/recovery?expires_in=",
+		},
+		{
+			name:       "unknown email",
+			email:      "x@b.c",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			link, err := kratox.Kratox.CreateRecoveryLinkForEmail(ctx, tt.email, tt.expiresIn)
+			code, codeErr := kratox.Kratox.CreateRecoveryCodeForEmail(ctx, tt.email, tt.expiresIn)
+			if tt.wantStatus != 0 {
+				var e *errorx.Error
+				if !errors.As(err, &e) || e.StatusCode != tt.wantStatus || !errors.Is(err, kratox.ErrIdentityNotFound) {
+					t.Errorf("CreateRecoveryLinkForEmail() error = %v, want status %d", err, tt.wantStatus)
+				}
+				if codeErr == nil {
+					t.Error("CreateRecoveryCodeForEmail() expected an error")
+				}
+				return
+			}
+			if err != nil || codeErr != nil {
+				t.Fatalf("unexpected errors %v, %v", err, codeErr)
+			}
+			if link.RecoveryLink != tt.wantLink {
+				t.Errorf("CreateRecoveryLinkForEmail() link = %s, want %s", link.RecoveryLink, tt.wantLink)
+			}
+			if code.RecoveryCode != "123456" || code.RecoveryLink != tt.wantLink {
+				t.Errorf("CreateRecoveryCodeForEmail() = %+v", code)
+			}
+		})
+	}
+
+	if _, err := kratox.Kratox.CreateRecoveryLink(ctx, "unknown", time.Hour); err == nil {
+		t.Error("CreateRecoveryLink() expected an error for an unknown identity")
+	}
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	client "github.com/ory/kratos-client-go"
+
+	"github.com/w6d-io/x/errorx"
+	"github.com/w6d-io/x/logx"
+)
+
+// RegistrationMethod is the method used to submit the registration flow
+type RegistrationMethod string
+
+const (
+	// RegisterWithPassword submits the traits and the password
+	RegisterWithPassword RegistrationMethod = "password"
+	// RegisterWithCode submits the traits to get a one-time code, then the code
+	RegisterWithCode RegistrationMethod = "code"
+)
+
+var (
+	errUnknownRegistrationMethod = errorx.New("unknown registration method")
+	errNoTraits                  = errorx.New("traits are missing")
+)
+
+// RegistrationRequest holds the parameters of an api registration flow
+type RegistrationRequest struct {
+	// FlowID continues an existing flow, a new flow is created when empty
+	FlowID string
+	// Method used to submit the flow
+	Method RegistrationMethod
+	// Traits of the identity, any value encoded as json matching the identity schema,
+	// usually a struct with json tags
+	Traits interface{}
+	// Password for the password method
+	Password string
+	// Code is the one-time code of the code method
+	Code string
+	// TransientPayload is passed to the registration webhooks, not stored
+	TransientPayload map[string]interface{}
+}
+
+// Register performs an api registration flow so the kratos hooks run, unlike CreateIdentity.
+// A new flow is created unless FlowID is set. When kratos rejects the submission a *FlowError
+// is returned, the validation messages of the traits are indexed by their name (ex: traits.email).
+// With the code method, submitting without Code sends the code and returns a *FlowError
+// with the sent_email state, the code is then submitted on the same flow with the same traits
+// if kratos is unreachable or an other issues, return nil with statusCode of the call and error-go
+func (a auth) Register(ctx context.Context, rr RegistrationRequest) (*client.SuccessfulNativeRegistration, error) {
+	log := logx.WithName(ctx, "Register")
+	if rr.Traits == nil {
+		log.Error(errNoTraits, "invalid registration request")
+		return nil, errorx.NewHTTP(errNoTraits, http.StatusBadRequest, "traits are missing")
+	}
+	body := map[string]interface{}{
+		"method": rr.Method,
+		"traits": rr.Traits,
+	}
+	switch rr.Method {
+	case RegisterWithPassword:
+		body["password"] = rr.Password
+	case RegisterWithCode:
+		if rr.Code != "" {
+			body["code"] = rr.Code
+		}
+	default:
+		log.Error(errUnknownRegistrationMethod, "invalid registration request", "method", rr.Method)
+		return nil, errorx.NewHTTP(errUnknownRegistrationMethod, http.StatusBadRequest, "unknown registration method")
+	}
+	if rr.TransientPayload != nil {
+		body["transient_payload"] = rr.TransientPayload
+	}
+
+	flowID := rr.FlowID
+	if flowID == "" {
+		var flow flowBody
+		if err := a.flowCall(ctx, http.MethodGet, "/self-service/registration/api", nil, Credential{}, nil, &flow); err != nil {
+			log.Error(err, "create registration flow failed")
+			return nil, err
+		}
+		flowID = flow.ID
+	}
+
+	out := &client.SuccessfulNativeRegistration{}
+	if err := a.flowCall(ctx, http.MethodPost, "/self-service/registration", url.Values{"flow": []string{flowID}}, Credential{}, body, out); err != nil {
+		return nil, err
+	}
+	log.V(1).Info("registered", "identity", out.Identity.Id)
+	return out, nil
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/w6d-io/x/errorx"
+	"github.com/w6d-io/x/logx"
+)
+
+var (
+	errNoValidator = errorx.New("registration validator not set")
+)
+
+// IdentityChanges are the parts of the identity replaced by kratos before it is persisted,
+// the nil ones are kept as submitted
+type IdentityChanges struct {
+	// Traits replace the submitted traits, they are validated against the identity schema
+	Traits interface{} `json:"traits,omitempty"`
+	// MetadataPublic replaces the public metadata
+	MetadataPublic interface{} `json:"metadata_public,omitempty"`
+	// MetadataAdmin replaces the admin metadata
+	MetadataAdmin interface{} `json:"metadata_admin,omitempty"`
+}
+
+// RegistrationValidator validates the registration posted by kratos. It accepts it as is by
+// returning nil, modifies the identity by returning the changes or blocks it by returning
+// a *WebhookInterrupt holding the field messages
+type RegistrationValidator func(context.Context, *Event) (*IdentityChanges, error)
+
+// RegistrationHook is an http.Handler for the web_hook run by kratos before the identity of a
+// registration is persisted. The hook has to be configured with response.parse for kratos to
+// read the answer, ex:
+//
+//	selfservice.flows.registration.after.password.hooks:
+//	  - hook: web_hook
+//	    config:
+//	      url: http://service/hooks/registration
+//	      method: POST
+//	      body: base64://ZnVuY3Rpb24oY3R4KSB7IGZsb3c6IGN0eC5mbG93LCBpZGVudGl0eTogY3R4LmlkZW50aXR5IH0=
+//	      response:
+//	        parse: true
+type RegistrationHook struct {
+	WebhookAuth
+	// Validate is called with the posted flow and identity
+	Validate RegistrationValidator
+}
+
+var _ http.Handler = &RegistrationHook{}
+
+// NewRegistrationHook returns the hook verifying the credential and calling the validator
+func NewRegistrationHook(auth WebhookAuth, validate RegistrationValidator) *RegistrationHook {
+	return &RegistrationHook{
+		WebhookAuth: auth,
+		Validate:    validate,
+	}
+}
+
+// registrationHookResponse is the answer parsed by kratos
+type registrationHookResponse struct {
+	Identity *IdentityChanges `json:"identity,omitempty"`
+}
+
+// ServeHTTP verifies and decodes the payload then answers kratos with the validator result,
+// the interrupt is written with the bad request status and the other errors with their status code
+func (h *RegistrationHook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	log := logx.WithName(r.Context(), "RegistrationHook")
+	if h.Validate == nil {
+		log.Error(errNoValidator, "validate registration failed")
+		writeWebhookError(w, errorx.NewHTTP(errNoValidator, http.StatusInternalServerError, "validator not set"))
+		return
+	}
+	e, err := readEvent(w, r, h.WebhookAuth)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	changes, err := h.Validate(r.Context(), e)
+	if err != nil {
+		log.Error(err, "validate registration failed", "flow", e.Flow.ID)
+		writeWebhookError(w, err)
+		return
+	}
+	rsp := registrationHookResponse{}
+	if changes != nil && (changes.Traits != nil || changes.MetadataPublic != nil || changes.MetadataAdmin != nil) {
+		rsp.Identity = changes
+	}
+	log.V(1).Info("registration validated", "flow", e.Flow.ID, "modified", rsp.Identity != nil)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(rsp)
+}
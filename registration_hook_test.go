@@ -0,0 +1,85 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/w6d-io/kratox"
+)
+
+func TestRegistrationHook(t *testing.T) {
+	validate := func(ctx context.Context, e *kratox.Event) (*kratox.IdentityChanges, error) {
+		traits, _ := e.Identity.Traits.(map[string]interface{})
+		switch traits["email"] {
+		case "taken@b.c":
+			return nil, kratox.NewInterrupt().Trait("email", 4000007, "email is already used")
+		case "UPPER@B.C":
+			return &kratox.IdentityChanges{
+				Traits:        map[string]interface{}{"email": "upper@b.c"},
+				MetadataAdmin: map[string]interface{}{"source": "hook"},
+			}, nil
+		case "down@b.c":
+			return nil, errors.New("database down")
+		}
+		return nil, nil
+	}
+	hook := kratox.NewRegistrationHook(kratox.WebhookAuth{Username: "kratos", Password: "p"}, validate)
+
+	tests := []struct {
+		name            string
+		hook            *kratox.RegistrationHook
+		email           string
+		password        string
+		unauthenticated bool
+		wantStatus      int
+		wantBody        string
+	}{
+		{name: "accept", hook: hook, email: "a@b.c", password: "p", wantStatus: http.StatusOK, wantBody: `{}`},
+		{name: "modify", hook: hook, email: "UPPER@B.C", password: "p", wantStatus: http.StatusOK,
+			wantBody: `{"identity":{"traits":{"email":"upper@b.c"},"metadata_admin":{"source":"hook"}}}`},
+		{name: "interrupt", hook: hook, email: "taken@b.c", password: "p", wantStatus: http.StatusBadRequest,
+			wantBody: `{"messages":[{"instance_ptr":"#/traits/email","messages":[{"id":4000007,"type":"error","text":"email is already used"}]}]}`},
+		{name: "validator failure", hook: hook, email: "down@b.c", password: "p", wantStatus: http.StatusInternalServerError},
+		{name: "wrong password", hook: hook, email: "a@b.c", password: "x", wantStatus: http.StatusUnauthorized},
+		{name: "unauthenticated", hook: hook, email: "a@b.c", unauthenticated: true, wantStatus: http.StatusUnauthorized},
+		{name: "no credential configured", hook: kratox.NewRegistrationHook(kratox.WebhookAuth{}, validate), email: "a@b.c",
+			unauthenticated: true, wantStatus: http.StatusUnauthorized},
+		{name: "no validator", hook: &kratox.RegistrationHook{}, email: "a@b.c", wantStatus: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"flow":{"id":"flow-id","type":"browser"},"identity":{"id":"00000000-0000-0000-0000-000000000000","schema_id":"default","schema_url":"","traits":{"email":"` + tt.email + `"}}}`
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+			if !tt.unauthenticated {
+				req.SetBasicAuth("kratos", tt.password)
+			}
+			w := httptest.NewRecorder()
+			tt.hook.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("ServeHTTP() status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && strings.TrimSpace(w.Body.String()) != tt.wantBody {
+				t.Errorf("ServeHTTP() body = %s, want %s", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
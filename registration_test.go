@@ -0,0 +1,142 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/w6d-io/kratox"
+)
+
+type traits struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+func fakeRegistration(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/self-service/registration/api", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"id": "flow-id", "type": "api", "ui": map[string]interface{}{"nodes": []interface{}{}}})
+	})
+	mux.HandleFunc("/self-service/registration", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		var body struct {
+			Method   string `json:"method"`
+			Password string `json:"password"`
+			Code     string `json:"code"`
+			Traits   traits `json:"traits"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode registration body: %v", err)
+		}
+		switch {
+		case body.Traits.Email == "":
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(flowWithMessages("flow-id", "choose_method", "traits.email", "Property email is missing."))
+		case body.Method == "code" && body.Code == "":
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(flowWithMessages("flow-id", "sent_email", "code", "An email containing a code has been sent."))
+		case body.Method == "password" && len(body.Password) < 8:
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(flowWithMessages("flow-id", "choose_method", "password", "The password must be at least 8 characters long."))
+		default:
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"identity": map[string]interface{}{"id": "identity-id", "schema_id": "default", "schema_url": "", "traits": body.Traits},
+			})
+		}
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestRegister(t *testing.T) {
+	srv := fakeRegistration(t)
+	defer srv.Close()
+	kratox.SetAddress(srv.URL, srv.URL)
+
+	tests := []struct {
+		name      string
+		req       kratox.RegistrationRequest
+		wantField string
+		wantState string
+		wantErr   bool
+	}{
+		{
+			name: "password",
+			req:  kratox.RegistrationRequest{Method: kratox.RegisterWithPassword, Traits: traits{Email: "a@b.c"}, Password: "long enough"},
+		},
+		{
+			name:      "weak password",
+			req:       kratox.RegistrationRequest{Method: kratox.RegisterWithPassword, Traits: traits{Email: "a@b.c"}, Password: "short"},
+			wantField: "password",
+			wantState: "choose_method",
+			wantErr:   true,
+		},
+		{
+			name:      "missing trait",
+			req:       kratox.RegistrationRequest{Method: kratox.RegisterWithPassword, Traits: traits{Name: "a"}, Password: "long enough"},
+			wantField: "traits.email",
+			wantState: "choose_method",
+			wantErr:   true,
+		},
+		{
+			name:      "code sent",
+			req:       kratox.RegistrationRequest{Method: kratox.RegisterWithCode, Traits: traits{Email: "a@b.c"}},
+			wantField: "code",
+			wantState: "sent_email",
+			wantErr:   true,
+		},
+		{
+			name: "code submitted on the same flow",
+			req:  kratox.RegistrationRequest{FlowID: "flow-id", Method: kratox.RegisterWithCode, Traits: traits{Email: "a@b.c"}, Code: "123456"},
+		},
+		{
+			name:    "no traits",
+			req:     kratox.RegistrationRequest{Method: kratox.RegisterWithPassword},
+			wantErr: true,
+		},
+		{
+			name:    "unknown method",
+			req:     kratox.RegistrationRequest{Method: "magic", Traits: traits{Email: "a@b.c"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := kratox.Kratox.Register(context.Background(), tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Register() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantField != "" {
+				var fe *kratox.FlowError
+				if !errors.As(err, &fe) {
+					t.Fatalf("Register() error = %v, want a FlowError", err)
+				}
+				if fe.State != tt.wantState || len(fe.Fields[tt.wantField]) == 0 {
+					t.Errorf("Register() flow error = %+v", fe)
+				}
+			}
+			if err == nil && got.Identity.Id != "identity-id" {
+				t.Errorf("Register() = %+v", got)
+			}
+		})
+	}
+}
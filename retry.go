@@ -0,0 +1,253 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/w6d-io/x/errorx"
+	"github.com/w6d-io/x/logx"
+)
+
+var (
+	// ErrKratosUnavailable is returned with the 503 status when the circuit breaker is open,
+	// kratos failed too many times in a row
+	ErrKratosUnavailable = errorx.New("kratos unavailable")
+
+	// Retry is the retry policy of the idempotent calls to kratos, as getting the session or an identity
+	Retry = RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     2 * time.Second,
+	}
+
+	// Breaker is the circuit breaker of the calls to kratos, disabled when nil
+	Breaker = NewCircuitBreaker(5, 30*time.Second)
+
+	// httpClient is the client of all the calls to kratos
+	httpClient = &http.Client{Transport: &kratosTransport{}}
+)
+
+// RetryPolicy is the retry with exponential backoff and jitter of the calls to kratos.
+// Only the GET calls are retried, when kratos is unreachable or responds 502, 503 or 504
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts, including the first one. There is no retry below 2
+	MaxAttempts int `json:"maxAttempts" mapstructure:"maxAttempts"`
+	// InitialBackoff is the pause before the first retry, doubled at each attempt
+	InitialBackoff time.Duration `json:"initialBackoff" mapstructure:"initialBackoff"`
+	// MaxBackoff caps the pause between two attempts
+	MaxBackoff time.Duration `json:"maxBackoff" mapstructure:"maxBackoff"`
+}
+
+// backoff returns the pause before the attempt, between the half and the whole
+// of the exponential backoff
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.InitialBackoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// CircuitBreaker fails the calls to a kratos host fast once it failed Threshold times in a row.
+// After Cooldown one call is let through, its success closes the circuit again
+type CircuitBreaker struct {
+	// Threshold is the number of failures in a row opening the circuit
+	Threshold int
+	// Cooldown is the time the circuit stays open
+	Cooldown time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*circuit
+}
+
+// circuit is the state of the calls to a host
+type circuit struct {
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// NewCircuitBreaker returns the circuit breaker opening after threshold failures in a row for the cooldown
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		Threshold: threshold,
+		Cooldown:  cooldown,
+		hosts:     map[string]*circuit{},
+	}
+}
+
+// Open reports whether the calls to the host are failed fast
+func (b *CircuitBreaker) Open(host string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.circuit(host)
+	return c.failures >= b.Threshold && (time.Now().Before(c.openUntil) || c.probing)
+}
+
+// allow reports whether the call to the host can be made, it lets one call through
+// when the cooldown is over
+func (b *CircuitBreaker) allow(host string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.circuit(host)
+	if c.failures < b.Threshold {
+		return true
+	}
+	if time.Now().Before(c.openUntil) || c.probing {
+		return false
+	}
+	c.probing = true
+	return true
+}
+
+// record records the result of the call to the host
+func (b *CircuitBreaker) record(host string, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.circuit(host)
+	c.probing = false
+	if ok {
+		c.failures = 0
+		return
+	}
+	c.failures++
+	if c.failures >= b.Threshold {
+		c.openUntil = time.Now().Add(b.Cooldown)
+	}
+}
+
+// release lets another call through when the call to the host was canceled by the caller,
+// its result says nothing about kratos
+func (b *CircuitBreaker) release(host string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.circuit(host).probing = false
+}
+
+func (b *CircuitBreaker) circuit(host string) *circuit {
+	if b.hosts == nil {
+		b.hosts = map[string]*circuit{}
+	}
+	c, ok := b.hosts[host]
+	if !ok {
+		c = &circuit{}
+		b.hosts[host] = c
+	}
+	return c
+}
+
+// kratosTransport applies the retry policy and the circuit breaker to the calls to kratos
+type kratosTransport struct {
+	// base is the transport making the calls, http.DefaultTransport when nil
+	base http.RoundTripper
+}
+
+var _ http.RoundTripper = &kratosTransport{}
+
+// RoundTrip implements http.RoundTripper, the timeout of the call is applied when the context
+// has no deadline. It covers the retries and the read of the response body
+func (t *kratosTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	parent := req.Context()
+	ctx, cancel := withTimeout(parent, Timeout.of(req))
+	rsp, err := t.retry(parent, req.WithContext(ctx))
+	if err != nil || rsp == nil {
+		cancel()
+		return rsp, err
+	}
+	rsp.Body = &cancelBody{ReadCloser: rsp.Body, cancel: cancel}
+	return rsp, nil
+}
+
+// retry sends the request with the retry policy and the circuit breaker. A call canceled by
+// the parent context is not recorded by the circuit breaker, a call timed out is
+func (t *kratosTransport) retry(parent context.Context, req *http.Request) (*http.Response, error) {
+	log := logx.WithName(req.Context(), "kratosTransport")
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	policy, breaker := Retry, Breaker
+	attempts := 1
+	if idempotent(req) && policy.MaxAttempts > 1 {
+		attempts = policy.MaxAttempts
+	}
+	host := req.URL.Host
+	for attempt := 1; ; attempt++ {
+		if breaker != nil && !breaker.allow(host) {
+			log.V(1).Info("circuit open", "host", host)
+			return nil, fmt.Errorf("%w: %s", ErrKratosUnavailable, host)
+		}
+		rsp, err := base.RoundTrip(req)
+		if parent.Err() != nil {
+			if breaker != nil {
+				breaker.release(host)
+			}
+			return rsp, err
+		}
+		failed := unavailable(rsp, err)
+		if breaker != nil {
+			breaker.record(host, !failed)
+		}
+		if !failed || attempt >= attempts {
+			return rsp, err
+		}
+		pause := policy.backoff(attempt)
+		if deadline, ok := req.Context().Deadline(); ok && time.Until(deadline) < pause {
+			log.V(1).Info("no time left to retry", "host", host, "path", req.URL.Path, "attempt", attempt)
+			return rsp, err
+		}
+		if rsp != nil {
+			_, _ = io.Copy(io.Discard, rsp.Body)
+			_ = rsp.Body.Close()
+		}
+		log.V(1).Info("retry kratos call", "host", host, "path", req.URL.Path, "attempt", attempt, "backoff", pause)
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(pause):
+		}
+	}
+}
+
+// idempotent reports whether the request can be sent again
+func idempotent(req *http.Request) bool {
+	return (req.Method == http.MethodGet || req.Method == http.MethodHead) && (req.Body == nil || req.Body == http.NoBody)
+}
+
+// unavailable reports whether kratos is unreachable or its gateway reports it down
+func unavailable(rsp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch rsp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
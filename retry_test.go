@@ -0,0 +1,168 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/w6d-io/kratox"
+	"github.com/w6d-io/x/errorx"
+)
+
+// flakyKratos fails the first calls with the 503 status, then serves the identity and the session
+type flakyKratos struct {
+	failures int32
+	calls    int32
+}
+
+func (f *flakyKratos) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if atomic.AddInt32(&f.calls, 1) <= atomic.LoadInt32(&f.failures) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	identity := `{"id":"identity-id","schema_id":"default","schema_url":"","traits":{}}`
+	switch {
+	case r.URL.Path == "/sessions/whoami":
+		_, _ = w.Write([]byte(`{"id":"session-id","active":true,"identity":` + identity + `}`))
+	case strings.HasPrefix(r.URL.Path, "/admin/identities"):
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+		}
+		_, _ = w.Write([]byte(identity))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// withResilience sets the retry policy and a new circuit breaker for the test
+func withResilience(t *testing.T, retry kratox.RetryPolicy, breaker *kratox.CircuitBreaker) {
+	retryBefore, breakerBefore := kratox.Retry, kratox.Breaker
+	kratox.Retry, kratox.Breaker = retry, breaker
+	t.Cleanup(func() {
+		kratox.Retry, kratox.Breaker = retryBefore, breakerBefore
+	})
+}
+
+func TestRetry(t *testing.T) {
+	withResilience(t, kratox.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}, nil)
+	ctx := context.Background()
+	tests := []struct {
+		name      string
+		failures  int32
+		call      func() error
+		wantErr   bool
+		wantCalls int32
+	}{
+		{
+			name:     "get identity retried",
+			failures: 2,
+			call: func() error {
+				_, err := kratox.Kratox.GetIdentity(ctx, "identity-id")
+				return err
+			},
+			wantCalls: 3,
+		},
+		{
+			name:     "get session retried",
+			failures: 1,
+			call: func() error {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.AddCookie(&http.Cookie{Name: kratox.CookieName, Value: "test"})
+				_, err := kratox.Kratox.GetSessionFromHTTP(ctx, req)
+				return err
+			},
+			wantCalls: 2,
+		},
+		{
+			name:     "attempts exhausted",
+			failures: 5,
+			call: func() error {
+				_, err := kratox.Kratox.GetIdentity(ctx, "identity-id")
+				return err
+			},
+			wantErr:   true,
+			wantCalls: 3,
+		},
+		{
+			name:     "create identity not retried",
+			failures: 1,
+			call: func() error {
+				_, err := kratox.Kratox.CreateIdentity(ctx, "default", map[string]interface{}{})
+				return err
+			},
+			wantErr:   true,
+			wantCalls: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flaky := &flakyKratos{failures: tt.failures}
+			srv := httptest.NewServer(flaky)
+			defer srv.Close()
+			kratox.SetAddress(srv.URL, srv.URL)
+			if err := tt.call(); (err != nil) != tt.wantErr {
+				t.Fatalf("call error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if calls := atomic.LoadInt32(&flaky.calls); calls != tt.wantCalls {
+				t.Errorf("kratos called %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	breaker := kratox.NewCircuitBreaker(2, 50*time.Millisecond)
+	withResilience(t, kratox.RetryPolicy{}, breaker)
+	flaky := &flakyKratos{failures: 2}
+	srv := httptest.NewServer(flaky)
+	defer srv.Close()
+	kratox.SetAddress(srv.URL, srv.URL)
+	ctx := context.Background()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	for i := 0; i < 2; i++ {
+		if _, err := kratox.Kratox.GetIdentity(ctx, "identity-id"); err == nil {
+			t.Fatalf("GetIdentity() expected the kratos failure")
+		}
+	}
+	if !breaker.Open(host) {
+		t.Fatalf("Open() = false after the failures")
+	}
+	_, err := kratox.Kratox.GetIdentity(ctx, "identity-id")
+	var e *errorx.Error
+	if !errors.Is(err, kratox.ErrKratosUnavailable) || !errors.As(err, &e) || e.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("GetIdentity() error = %v, want %v with the 503 status", err, kratox.ErrKratosUnavailable)
+	}
+	if calls := atomic.LoadInt32(&flaky.calls); calls != 2 {
+		t.Errorf("kratos called %d times while the circuit is open", calls)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if _, err := kratox.Kratox.GetIdentity(ctx, "identity-id"); err != nil {
+		t.Fatalf("GetIdentity() after the cooldown error = %v", err)
+	}
+	if breaker.Open(host) {
+		t.Errorf("Open() = true after a success")
+	}
+}
@@ -2,8 +2,11 @@ package kratox
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
 
 	client "github.com/ory/kratos-client-go"
 	"google.golang.org/grpc/metadata"
@@ -18,85 +21,123 @@ const (
 )
 
 var (
-	errNoCookie             = errorx.New(CookieName + " cookie not found")
 	errNoMDFromCtx          = errorx.New("cannot get metadata from context")
 	errSessNotFoundInCtx    = errorx.New("session not found in context")
 	errAddressNotFoundInCtx = errorx.New("address not found in context")
+	errNoTokenizedSession   = errorx.New("session has not been tokenized")
+	errNoCredential         = errorx.New("session credential not found")
 )
 
 // GetSessionFromHTTP is used to check if the session cookie is active ( ex: session.GetActive() )
 // and also return user information
+// The session credential is read by the Extractors
 // if session is not set, return a nil session with StatusBadRequest and error
 // if kratos is unreachable or an other issues, return nil session with statusCode of the call and error-go
 func (a auth) GetSessionFromHTTP(ctx context.Context, req *http.Request) (*client.Session, error) {
 	log := logx.WithName(ctx, "GetSessionFromHTTP")
 
-	cookie, err := req.Cookie(CookieName)
-	if err != nil {
-		log.Error(err, "get ory_kratos_session from cookie failed")
-		return nil, errorx.NewHTTP(err, http.StatusBadRequest, "get ory_kratos_session from cookie failed")
+	cred, ok := extractor().FromHTTP(req)
+	if !ok {
+		log.Error(errNoCredential, "get session credential from http request failed")
+		return nil, errorx.NewHTTP(errNoCredential, http.StatusBadRequest, "get session credential from http request failed")
 	}
-	log.V(2).Info("cookie", "value", cookie.Value)
-	return a.do(ctx, cookie.Value)
+	return a.do(ctx, cred)
 }
 
 // GetSessionFromGRPCCtx is used to forward a session stock into a context.
 // It checks if session on context is present
+// The session credential is read from the metadata by the Extractors
 // if session is not set, return a nil session with StatusBadRequest and error
 // if kratos is unreachable or an other issues, return nil session with statusCode of the call and error-go
 func (a auth) GetSessionFromGRPCCtx(ctx context.Context) (*client.Session, error) {
 	log := logx.WithName(ctx, "GetSessionFromGRPCCtx")
 
 	//get metadata from ctx
-	md, ok := metadata.FromIncomingContext(ctx)
-	if !ok {
+	if _, ok := metadata.FromIncomingContext(ctx); !ok {
 		log.Error(errNoMDFromCtx, "metadata boolean from metadata.FromIncomingContext(ctx) = %v", ok)
 		return nil, errorx.NewHTTP(errNoMDFromCtx, http.StatusNotFound, "fail to get metadata")
 	}
 
-	// check if session is present on our metadata
-	if _, ok := md[CookieName]; !ok {
-		log.Error(errNoCookie, `metadata "%s" doesn't exist`, CookieName)
-		return nil, errorx.NewHTTP(errNoCookie, http.StatusNotFound, "bad metadata")
-	}
-
-	// check if we have more than zero value for this key cause MD is map[string][]string
-	if len(md[CookieName]) == 0 || len(md[CookieName][0]) == 0 {
-		log.Error(errNoCookie, "metadata \"%s\" exist but no value exist", CookieName)
-		return nil, errorx.NewHTTP(errNoCookie, http.StatusNotFound, "empty metadata")
+	cred, ok := extractor().FromGRPC(ctx)
+	if !ok {
+		log.Error(errNoCredential, "get session credential from metadata failed")
+		return nil, errorx.NewHTTP(errNoCredential, http.StatusNotFound, "bad metadata")
 	}
-	return a.do(ctx, md[CookieName][0])
+	return a.do(ctx, cred)
 }
 
-func (a auth) do(ctx context.Context, cookie string) (*client.Session, error) {
+func (a auth) do(ctx context.Context, cred Credential) (*client.Session, error) {
 	log := logx.WithName(ctx, "GetSessionFromCtx")
-	u, err := a.getKratosAddress()
+	api, err := a.publicAPI()
 	if err != nil {
-		return nil, errorx.NewHTTP(err, http.StatusInternalServerError, "fail to get kratos address")
+		return nil, err
 	}
-	cfg := client.NewConfiguration()
-	cfg.Scheme = u.Scheme
-	cfg.Host = u.Host
-	cfg.Servers = []client.ServerConfiguration{
-		{
-			URL: u.String(),
-		},
-	}
-	api := client.NewAPIClient(cfg)
-	log.V(2).Info("making call to kratos.GetSession", "session_id", cookie)
+	log.V(2).Info("making call to kratos.GetSession")
 
-	sess, rsp, err := api.FrontendApi.ToSession(ctx).Cookie(fmt.Sprintf("%s=%s", CookieName, cookie)).Execute()
+	req := api.FrontendApi.ToSession(ctx)
+	if cred.Token != "" {
+		req = req.XSessionToken(cred.Token)
+	} else {
+		req = req.Cookie(fmt.Sprintf("%s=%s", a.sessionCookie(), cred.Cookie))
+	}
+	sess, rsp, err := req.Execute()
 	if err != nil {
 		log.Error(err, "get session failed")
-		status := http.StatusInternalServerError
-		if rsp != nil {
-			status = rsp.StatusCode
-		}
-		return nil, errorx.NewHTTP(err, status, "get session failed")
+		return nil, errorx.NewHTTP(err, statusOf(rsp, err), "get session failed")
 	}
 	return sess, nil
 }
 
+// TokenizeSession exchanges the session cookie or token recorded into the context for a json web token
+// built by the kratos session tokenizer with the template
+// if kratos is unreachable or an other issues, return an empty token with statusCode of the call and error-go
+func (a auth) TokenizeSession(ctx context.Context, template string) (string, error) {
+	log := logx.WithName(ctx, "TokenizeSession")
+	cred := GetCredentialFromCtx(ctx)
+	if cred.IsZero() {
+		log.Error(errNoCredential, "get session credential from context failed")
+		return "", errorx.NewHTTP(errNoCredential, http.StatusUnauthorized, "get session credential failed")
+	}
+	u, err := a.getKratosAddress()
+	if err != nil {
+		return "", errorx.NewHTTP(err, http.StatusInternalServerError, "fail to get kratos address")
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/sessions/whoami"
+	u.RawQuery = url.Values{"tokenize_as": []string{template}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", errorx.NewHTTP(err, http.StatusInternalServerError, "build request failed")
+	}
+	if cred.Token != "" {
+		req.Header.Set(SessionTokenHeader, cred.Token)
+	} else {
+		req.Header.Set("Cookie", fmt.Sprintf("%s=%s", a.sessionCookie(), cred.Cookie))
+	}
+	req.Header.Set("Accept", "application/json")
+	rsp, err := httpClient.Do(req)
+	if err != nil {
+		log.Error(err, "tokenize session failed")
+		return "", errorx.NewHTTP(err, statusOf(nil, err), "tokenize session failed")
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("kratos responded %s", rsp.Status)
+		log.Error(err, "tokenize session failed")
+		return "", errorx.NewHTTP(err, rsp.StatusCode, "tokenize session failed")
+	}
+	sess := &client.Session{}
+	if err := json.NewDecoder(rsp.Body).Decode(sess); err != nil {
+		log.Error(err, "decode session failed")
+		return "", errorx.NewHTTP(err, http.StatusInternalServerError, "decode session failed")
+	}
+	token, ok := sess.AdditionalProperties["tokenized"].(string)
+	if !ok || token == "" {
+		log.Error(errNoTokenizedSession, "tokenize session failed", "template", template)
+		return "", errorx.NewHTTP(errNoTokenizedSession, http.StatusInternalServerError, "tokenize session failed")
+	}
+	return token, nil
+}
+
 // GetSessionFromCtx return session from context or return an error
 func GetSessionFromCtx(ctx context.Context) (*client.Session, error) {
 	s := ctx.Value(SessionKey)
@@ -180,22 +221,57 @@ func SetCookieInCtx(ctx context.Context, cookie string) context.Context {
 	return ctx
 }
 
+// GetSessionTokenFromCtx return the session token from context
+func GetSessionTokenFromCtx(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	t := ctx.Value(TokenKey)
+	if t == nil {
+		return ""
+	}
+	token, ok := t.(string)
+	if !ok {
+		return ""
+	}
+	return token
+}
+
+// SetSessionTokenInCtx record the session token into context
+func SetSessionTokenInCtx(ctx context.Context, token string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if token == "" {
+		return ctx
+	}
+	ctx = context.WithValue(ctx, TokenKey, token)
+	return ctx
+}
+
+// GetCredentialFromCtx return the session cookie and token recorded into context
+func GetCredentialFromCtx(ctx context.Context) Credential {
+	return Credential{Cookie: GetCookieFromCtx(ctx), Token: GetSessionTokenFromCtx(ctx)}
+}
+
+// SetCredentialInCtx record the session cookie or token into context
+func SetCredentialInCtx(ctx context.Context, cred Credential) context.Context {
+	return SetSessionTokenInCtx(SetCookieInCtx(ctx, cred.Cookie), cred.Token)
+}
+
 // SetCookieFromHttpToCtx record ory_kratos_session into context
+// The session credential is read by the Extractors, a session token is recorded as well
 func SetCookieFromHttpToCtx(ctx context.Context, req *http.Request) (context.Context, error) {
 	log := logx.WithName(ctx, "GetSessionFromCtx")
 	if ctx == nil {
 		ctx = context.Background()
 	}
-	cookie, err := req.Cookie(CookieName)
-	if err != nil {
-		log.Error(err, "get ory_kratos_session cookie failed")
-		return nil, errorx.NewHTTP(err, http.StatusUnauthorized, "get ory_kratos_session cookie failed")
-	}
-	if cookie.Value == "" {
-		return nil, errorx.NewHTTP(nil, http.StatusUnauthorized, "get ory_kratos_session cookie failed")
+	cred, ok := extractor().FromHTTP(req)
+	if !ok {
+		log.Error(errNoCredential, "get session credential failed")
+		return nil, errorx.NewHTTP(errNoCredential, http.StatusUnauthorized, "get ory_kratos_session cookie failed")
 	}
-	ctx = context.WithValue(ctx, CookieKey, cookie.Value)
-	return ctx, nil
+	return SetCredentialInCtx(ctx, cred), nil
 }
 
 func GetSession(ctx context.Context) error {
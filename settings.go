@@ -0,0 +1,222 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	client "github.com/ory/kratos-client-go"
+
+	"github.com/w6d-io/x/errorx"
+	"github.com/w6d-io/x/logx"
+)
+
+// SettingsMethod is the method used to submit the settings flow
+type SettingsMethod string
+
+const (
+	// SettingsProfile updates the traits
+	SettingsProfile SettingsMethod = "profile"
+	// SettingsPassword changes the password
+	SettingsPassword SettingsMethod = "password"
+	// SettingsTOTP links or unlinks the totp authenticator
+	SettingsTOTP SettingsMethod = "totp"
+	// SettingsLookupSecret manages the backup codes
+	SettingsLookupSecret SettingsMethod = "lookup_secret"
+)
+
+// LookupSecretAction is what is done on the backup codes
+type LookupSecretAction string
+
+const (
+	// LookupSecretRegenerate generates new codes, they have to be confirmed
+	LookupSecretRegenerate LookupSecretAction = "lookup_secret_regenerate"
+	// LookupSecretConfirm saves the regenerated codes
+	LookupSecretConfirm LookupSecretAction = "lookup_secret_confirm"
+	// LookupSecretReveal shows the current codes
+	LookupSecretReveal LookupSecretAction = "lookup_secret_reveal"
+	// LookupSecretDisable removes the codes
+	LookupSecretDisable LookupSecretAction = "lookup_secret_disable"
+)
+
+const (
+	// settingsSuccess is the state of a settings flow once the submission is saved
+	settingsSuccess = "success"
+	// lookupSecretCodesNode is the id of the node listing the backup codes
+	lookupSecretCodesNode = "lookup_secret_codes"
+)
+
+var (
+	errUnknownSettingsMethod = errorx.New("unknown settings method")
+)
+
+// SettingsRequest holds the parameters of a settings flow
+type SettingsRequest struct {
+	// FlowID continues an existing flow, a new flow is created when empty
+	FlowID string
+	// Method used to submit the flow
+	Method SettingsMethod
+	// Traits are the new traits of the profile method, any value encoded as json
+	Traits interface{}
+	// Password is the new password of the password method
+	Password string
+	// TOTPCode confirms the authenticator of the totp method
+	TOTPCode string
+	// TOTPUnlink removes the authenticator of the totp method
+	TOTPUnlink bool
+	// LookupSecret is the action of the lookup_secret method
+	LookupSecret LookupSecretAction
+	// Cookies are the csrf cookies of the browser flow to continue, from SettingsResult
+	Cookies []*http.Cookie
+}
+
+// SettingsResult is the state of the settings flow after the submission
+type SettingsResult struct {
+	// FlowID is the id of the flow to submit again, for instance to confirm the backup codes
+	FlowID string
+	// State is success once the submission is saved
+	State string
+	// Identity is the identity updated by the flow
+	Identity client.Identity
+	// LookupSecretCodes are the backup codes, on regenerate and reveal
+	LookupSecretCodes []string
+	// Cookies are the csrf cookies of the browser flow, used with a session cookie.
+	// They have to be sent back with FlowID to continue the flow
+	Cookies []*http.Cookie
+}
+
+// settingsBody is the settings flow returned by kratos
+type settingsBody struct {
+	flowBody
+	Identity client.Identity `json:"identity"`
+}
+
+// UpdateSettings submits a settings flow for the session recorded into the context.
+// With a session token an api flow is used, with a session cookie a browser flow with its csrf token.
+// A new flow is created unless FlowID is set. When kratos rejects the submission a *FlowError holding
+// the field messages is returned, when the session is too old to change the credentials
+// a *PrivilegedSessionError is returned, the user has to log in again with the refresh option
+// if kratos is unreachable or an other issues, return nil with statusCode of the call and error-go
+func (a auth) UpdateSettings(ctx context.Context, sr SettingsRequest) (*SettingsResult, error) {
+	log := logx.WithName(ctx, "UpdateSettings")
+	cred := GetCredentialFromCtx(ctx)
+	if cred.IsZero() {
+		log.Error(errNoCredential, "get session credential from context failed")
+		return nil, errorx.NewHTTP(errNoCredential, http.StatusUnauthorized, "get session credential failed")
+	}
+	body := map[string]interface{}{"method": sr.Method}
+	switch sr.Method {
+	case SettingsProfile:
+		body["traits"] = sr.Traits
+	case SettingsPassword:
+		body["password"] = sr.Password
+	case SettingsTOTP:
+		if sr.TOTPUnlink {
+			body["totp_unlink"] = true
+		} else {
+			body["totp_code"] = sr.TOTPCode
+		}
+	case SettingsLookupSecret:
+		body[string(sr.LookupSecret)] = true
+	default:
+		log.Error(errUnknownSettingsMethod, "invalid settings request", "method", sr.Method)
+		return nil, errorx.NewHTTP(errUnknownSettingsMethod, http.StatusBadRequest, "unknown settings method")
+	}
+
+	// browser flows reject the submission without the csrf token and its cookie
+	cookies := sr.Cookies
+	flowID := sr.FlowID
+	if flowID == "" || cred.Token == "" {
+		var flow settingsBody
+		var set []*http.Cookie
+		var err error
+		switch {
+		case flowID != "":
+			set, err = a.flowExchange(ctx, http.MethodGet, "/self-service/settings/flows", url.Values{"id": []string{flowID}}, cred, cookies, nil, &flow)
+		case cred.Token != "":
+			set, err = a.flowExchange(ctx, http.MethodGet, "/self-service/settings/api", nil, cred, nil, nil, &flow)
+		default:
+			set, err = a.flowExchange(ctx, http.MethodGet, "/self-service/settings/browser", nil, cred, nil, nil, &flow)
+		}
+		if err != nil {
+			log.Error(err, "get settings flow failed")
+			return nil, err
+		}
+		cookies = a.mergeCookies(cookies, set)
+		flowID = flow.ID
+		if csrf, ok := flow.value("csrf_token").(string); ok && csrf != "" {
+			body["csrf_token"] = csrf
+		}
+	}
+
+	var flow settingsBody
+	set, err := a.flowExchange(ctx, http.MethodPost, "/self-service/settings", url.Values{"flow": []string{flowID}}, cred, cookies, body, &flow)
+	if err != nil {
+		return nil, err
+	}
+	log.V(1).Info("settings submitted", "method", sr.Method, "state", flow.State)
+	return &SettingsResult{
+		FlowID:            flow.ID,
+		State:             flow.State,
+		Identity:          flow.Identity,
+		LookupSecretCodes: flow.lookupSecretCodes(),
+		Cookies:           a.mergeCookies(cookies, set),
+	}, nil
+}
+
+// Saved reports whether the submission is saved
+func (r *SettingsResult) Saved() bool {
+	return r.State == settingsSuccess
+}
+
+// lookupSecretCodes returns the backup codes listed by the flow
+func (f flowBody) lookupSecretCodes() []string {
+	var codes []string
+	for _, n := range f.UI.Nodes {
+		if n.Attributes.ID != lookupSecretCodesNode || n.Attributes.Text == nil {
+			continue
+		}
+		secrets, _ := n.Attributes.Text.Context["secrets"].([]interface{})
+		for _, s := range secrets {
+			secret, _ := s.(map[string]interface{})
+			if text, ok := secret["text"].(string); ok {
+				codes = append(codes, text)
+			}
+		}
+	}
+	return codes
+}
+
+// mergeCookies returns the cookies updated by the ones set by kratos, the session cookie excluded
+func (k Conn) mergeCookies(cookies, set []*http.Cookie) []*http.Cookie {
+	var merged []*http.Cookie
+	names := map[string]bool{}
+	for _, c := range set {
+		if c.Name == k.sessionCookie() || names[c.Name] {
+			continue
+		}
+		names[c.Name] = true
+		merged = append(merged, c)
+	}
+	for _, c := range cookies {
+		if !names[c.Name] {
+			merged = append(merged, c)
+		}
+	}
+	return merged
+}
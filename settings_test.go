@@ -0,0 +1,213 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/w6d-io/kratox"
+)
+
+func settingsFlow(state string, nodes ...interface{}) map[string]interface{} {
+	nodes = append(nodes, map[string]interface{}{
+		"type": "input", "group": "default", "messages": []interface{}{}, "meta": map[string]interface{}{},
+		"attributes": map[string]interface{}{"name": "csrf_token", "type": "hidden", "value": "csrf-value", "node_type": "input"},
+	})
+	return map[string]interface{}{
+		"id":       "flow-id",
+		"state":    state,
+		"identity": map[string]interface{}{"id": "identity-id", "schema_id": "default", "schema_url": "", "traits": map[string]string{"email": "a@b.c"}},
+		"ui":       map[string]interface{}{"nodes": nodes},
+	}
+}
+
+func fakeSettings(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	authenticated := func(w http.ResponseWriter, r *http.Request) bool {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get(kratox.SessionTokenHeader) == "old" {
+			w.WriteHeader(http.StatusForbidden)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":               map[string]interface{}{"id": "session_refresh_required", "code": 403},
+				"redirect_browser_to": "http://kratos/self-service/login/browser?refresh=true",
+			})
+			return false
+		}
+		if c, err := r.Cookie(kratox.CookieName); r.Header.Get(kratox.SessionTokenHeader) == "" && (err != nil || c.Value != "cookie") {
+			w.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]interface{}{"id": "session_inactive", "code": 401}})
+			return false
+		}
+		return true
+	}
+	mux.HandleFunc("/self-service/settings/api", func(w http.ResponseWriter, r *http.Request) {
+		if authenticated(w, r) {
+			_ = json.NewEncoder(w).Encode(settingsFlow("show_form"))
+		}
+	})
+	mux.HandleFunc("/self-service/settings/browser", func(w http.ResponseWriter, r *http.Request) {
+		if authenticated(w, r) {
+			http.SetCookie(w, &http.Cookie{Name: "csrf_token_abc", Value: "csrf-cookie"})
+			_ = json.NewEncoder(w).Encode(settingsFlow("show_form"))
+		}
+	})
+	mux.HandleFunc("/self-service/settings/flows", func(w http.ResponseWriter, r *http.Request) {
+		if authenticated(w, r) {
+			_ = json.NewEncoder(w).Encode(settingsFlow("show_form"))
+		}
+	})
+	mux.HandleFunc("/self-service/settings", func(w http.ResponseWriter, r *http.Request) {
+		if !authenticated(w, r) {
+			return
+		}
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode settings body: %v", err)
+		}
+		if r.Header.Get(kratox.SessionTokenHeader) == "" {
+			if c, err := r.Cookie("csrf_token_abc"); err != nil || c.Value != "csrf-cookie" || body["csrf_token"] != "csrf-value" {
+				w.WriteHeader(http.StatusForbidden)
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]interface{}{"id": "security_csrf_violation", "code": 403}})
+				return
+			}
+		}
+		switch {
+		case body["method"] == "password" && body["password"] == "weak":
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(flowWithMessages("flow-id", "show_form", "password", "The password has been found in data breaches."))
+		case body["lookup_secret_regenerate"] == true:
+			_ = json.NewEncoder(w).Encode(settingsFlow("show_form", map[string]interface{}{
+				"type": "text", "group": "lookup_secret", "messages": []interface{}{}, "meta": map[string]interface{}{},
+				"attributes": map[string]interface{}{"id": "lookup_secret_codes", "node_type": "text", "text": map[string]interface{}{
+					"id": 1050015, "type": "info", "text": "abc, def",
+					"context": map[string]interface{}{"secrets": []interface{}{
+						map[string]interface{}{"id": 1050009, "text": "abc", "type": "info"},
+						map[string]interface{}{"id": 1050009, "text": "def", "type": "info"},
+					}},
+				}},
+			}))
+		default:
+			_ = json.NewEncoder(w).Encode(settingsFlow("success"))
+		}
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestUpdateSettings(t *testing.T) {
+	srv := fakeSettings(t)
+	defer srv.Close()
+	kratox.SetAddress(srv.URL, srv.URL)
+
+	tests := []struct {
+		name        string
+		cred        kratox.Credential
+		req         kratox.SettingsRequest
+		wantSaved   bool
+		wantCodes   int
+		wantField   string
+		wantRefresh bool
+		wantErr     bool
+	}{
+		{
+			name:      "password with a session token",
+			cred:      kratox.Credential{Token: "token"},
+			req:       kratox.SettingsRequest{Method: kratox.SettingsPassword, Password: "strong password"},
+			wantSaved: true,
+		},
+		{
+			name:      "profile with a session cookie",
+			cred:      kratox.Credential{Cookie: "cookie"},
+			req:       kratox.SettingsRequest{Method: kratox.SettingsProfile, Traits: map[string]string{"email": "a@b.c"}},
+			wantSaved: true,
+		},
+		{
+			name:      "weak password",
+			cred:      kratox.Credential{Token: "token"},
+			req:       kratox.SettingsRequest{Method: kratox.SettingsPassword, Password: "weak"},
+			wantField: "password",
+			wantErr:   true,
+		},
+		{
+			name:        "privileged session required",
+			cred:        kratox.Credential{Token: "old"},
+			req:         kratox.SettingsRequest{Method: kratox.SettingsPassword, Password: "strong password"},
+			wantRefresh: true,
+			wantErr:     true,
+		},
+		{
+			name:      "regenerate backup codes",
+			cred:      kratox.Credential{Cookie: "cookie"},
+			req:       kratox.SettingsRequest{Method: kratox.SettingsLookupSecret, LookupSecret: kratox.LookupSecretRegenerate},
+			wantCodes: 2,
+		},
+		{
+			name:    "no session",
+			req:     kratox.SettingsRequest{Method: kratox.SettingsPassword},
+			wantErr: true,
+		},
+		{
+			name:    "unknown method",
+			cred:    kratox.Credential{Token: "token"},
+			req:     kratox.SettingsRequest{Method: "magic"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := kratox.SetCredentialInCtx(context.Background(), tt.cred)
+			got, err := kratox.Kratox.UpdateSettings(ctx, tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UpdateSettings() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var fe *kratox.FlowError
+			if tt.wantField != "" && (!errors.As(err, &fe) || len(fe.Fields[tt.wantField]) == 0) {
+				t.Errorf("UpdateSettings() error = %v, want a FlowError on %s", err, tt.wantField)
+			}
+			var pe *kratox.PrivilegedSessionError
+			if tt.wantRefresh && (!errors.As(err, &pe) || pe.RedirectBrowserTo == "") {
+				t.Errorf("UpdateSettings() error = %v, want a PrivilegedSessionError", err)
+			}
+			if err != nil {
+				return
+			}
+			if got.Saved() != tt.wantSaved || len(got.LookupSecretCodes) != tt.wantCodes || got.Identity.Id != "identity-id" {
+				t.Errorf("UpdateSettings() = %+v", got)
+			}
+		})
+	}
+
+	t.Run("continue a browser flow", func(t *testing.T) {
+		ctx := kratox.SetCredentialInCtx(context.Background(), kratox.Credential{Cookie: "cookie"})
+		first, err := kratox.Kratox.UpdateSettings(ctx, kratox.SettingsRequest{Method: kratox.SettingsLookupSecret, LookupSecret: kratox.LookupSecretRegenerate})
+		if err != nil {
+			t.Fatalf("UpdateSettings() error = %v", err)
+		}
+		got, err := kratox.Kratox.UpdateSettings(ctx, kratox.SettingsRequest{
+			FlowID:       first.FlowID,
+			Method:       kratox.SettingsLookupSecret,
+			LookupSecret: kratox.LookupSecretConfirm,
+			Cookies:      first.Cookies,
+		})
+		if err != nil || !got.Saved() {
+			t.Errorf("UpdateSettings() = %+v, %v", got, err)
+		}
+	})
+}
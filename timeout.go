@@ -0,0 +1,70 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	// Timeout holds the timeouts of the calls to kratos
+	Timeout = Timeouts{
+		Session: 5 * time.Second,
+		Admin:   30 * time.Second,
+	}
+)
+
+// Timeouts are the timeouts of the calls to kratos by kind of operation. They are applied only
+// when the context of the call has no deadline, the deadline of the caller is kept otherwise
+type Timeouts struct {
+	// Session is the timeout of the calls to the public api, as the session checks and the self-service flows
+	Session time.Duration `json:"session" mapstructure:"session"`
+	// Admin is the timeout of the calls to the admin api
+	Admin time.Duration `json:"admin" mapstructure:"admin"`
+}
+
+// of returns the timeout of the request, the admin api paths hold /admin/ after the address path
+func (t Timeouts) of(req *http.Request) time.Duration {
+	if strings.Contains(req.URL.Path, "/admin/") {
+		return t.Admin
+	}
+	return t.Session
+}
+
+// withTimeout returns the context with the timeout when it has no deadline and the timeout is set
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// cancelBody releases the context of the call once the response body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close implements io.Closer
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/w6d-io/kratox"
+)
+
+// withTimeouts sets the timeouts for the test
+func withTimeouts(t *testing.T, timeouts kratox.Timeouts) {
+	before := kratox.Timeout
+	kratox.Timeout = timeouts
+	t.Cleanup(func() {
+		kratox.Timeout = before
+	})
+}
+
+func TestTimeouts(t *testing.T) {
+	withResilience(t, kratox.RetryPolicy{}, nil)
+	hung := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-hung:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(hung)
+	kratox.SetAddress(srv.URL, srv.URL)
+
+	session := func(ctx context.Context) error {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(&http.Cookie{Name: kratox.CookieName, Value: "test"})
+		_, err := kratox.Kratox.GetSessionFromHTTP(ctx, req)
+		return err
+	}
+	identity := func(ctx context.Context) error {
+		_, err := kratox.Kratox.GetIdentity(ctx, "identity-id")
+		return err
+	}
+	tests := []struct {
+		name     string
+		timeouts kratox.Timeouts
+		deadline time.Duration
+		call     func(context.Context) error
+		max      time.Duration
+	}{
+		{name: "session timeout", timeouts: kratox.Timeouts{Session: 50 * time.Millisecond, Admin: time.Minute}, call: session, max: time.Second},
+		{name: "admin timeout", timeouts: kratox.Timeouts{Session: time.Minute, Admin: 50 * time.Millisecond}, call: identity, max: time.Second},
+		{name: "caller deadline kept", timeouts: kratox.Timeouts{Session: time.Minute, Admin: time.Minute}, deadline: 50 * time.Millisecond, call: identity, max: time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withTimeouts(t, tt.timeouts)
+			ctx := context.Background()
+			if tt.deadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.deadline)
+				defer cancel()
+			}
+			start := time.Now()
+			if err := tt.call(ctx); err == nil {
+				t.Fatalf("call to the hung kratos succeeded")
+			}
+			if elapsed := time.Since(start); elapsed > tt.max {
+				t.Errorf("call returned after %v, want less than %v", elapsed, tt.max)
+			}
+		})
+	}
+}
+
+func TestTimeouts_Retry(t *testing.T) {
+	withResilience(t, kratox.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Second}, nil)
+	withTimeouts(t, kratox.Timeouts{Admin: 200 * time.Millisecond})
+	flaky := &flakyKratos{failures: 1}
+	srv := httptest.NewServer(flaky)
+	defer srv.Close()
+	kratox.SetAddress(srv.URL, srv.URL)
+
+	start := time.Now()
+	if _, err := kratox.Kratox.GetIdentity(context.Background(), "identity-id"); err == nil {
+		t.Fatalf("GetIdentity() expected the kratos failure")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("GetIdentity() waited %v for a retry past the deadline", elapsed)
+	}
+	if calls := atomic.LoadInt32(&flaky.calls); calls != 1 {
+		t.Errorf("kratos called %d times, want 1", calls)
+	}
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+
+	"golang.org/x/oauth2"
+
+	"github.com/w6d-io/x/errorx"
+	"github.com/w6d-io/x/logx"
+)
+
+var (
+	errNoProviderConfig = errorx.New("no oauth2 config for the provider")
+	errNoRefreshToken   = errorx.New("no refresh token for the provider")
+)
+
+// TokenManager returns valid access tokens of the oidc providers linked to the identities.
+// The refresh token stored by kratos at sign up is exchanged for an access token,
+// kept until it expires
+type TokenManager struct {
+	// Configs holds the oauth2 config of the providers by kratos provider id
+	Configs map[string]*oauth2.Config
+	// HTTPClient is the client used to call the token endpoints, http.DefaultClient when nil
+	HTTPClient *http.Client
+
+	mu     sync.Mutex
+	tokens map[string]*oauth2.Token
+	// refreshing holds the lock of the tokens in use, the concurrent calls wait for the running
+	// refresh. A lock is dropped once no call holds or waits for it
+	refreshing map[string]*tokenLock
+}
+
+// tokenLock is the lock of a token with the number of calls holding or waiting for it
+type tokenLock struct {
+	sync.Mutex
+	users int
+}
+
+// NewTokenManager returns the token manager of the providers
+func NewTokenManager(configs map[string]*oauth2.Config) *TokenManager {
+	return &TokenManager{
+		Configs:    configs,
+		tokens:     map[string]*oauth2.Token{},
+		refreshing: map[string]*tokenLock{},
+	}
+}
+
+// AccessToken returns a valid access token of the provider for the identity of the session
+// recorded into the context. The token is refreshed when it is expired, once for the concurrent
+// calls of the same identity and provider. The token is dropped when it cannot be refreshed
+func (m *TokenManager) AccessToken(ctx context.Context, provider string) (*oauth2.Token, error) {
+	log := logx.WithName(ctx, "AccessToken")
+	cfg, ok := m.Configs[provider]
+	if !ok {
+		log.Error(errNoProviderConfig, "get oauth2 config failed", "provider", provider)
+		return nil, errorx.NewHTTP(errNoProviderConfig, http.StatusInternalServerError, "provider not configured")
+	}
+	sess, err := GetSessionFromCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key := sess.Identity.Id + "/" + provider
+
+	lock := m.lock(key)
+	lock.Lock()
+	defer m.unlock(key, lock)
+	cached := m.cached(key)
+	if cached.Valid() {
+		return cached, nil
+	}
+	refresh := ""
+	if cached != nil {
+		refresh = cached.RefreshToken
+	}
+	if refresh == "" {
+		p, err := Kratox.GetToken(ctx, provider)
+		if err != nil {
+			m.store(key, nil)
+			return nil, err
+		}
+		refresh = p.RefreshToken
+	}
+	if refresh == "" {
+		m.store(key, nil)
+		log.Error(errNoRefreshToken, "get refresh token failed", "provider", provider)
+		return nil, errorx.NewHTTP(errNoRefreshToken, http.StatusNotFound, "no refresh token for the provider")
+	}
+
+	if m.HTTPClient != nil {
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, m.HTTPClient)
+	}
+	token, err := cfg.TokenSource(ctx, &oauth2.Token{RefreshToken: refresh}).Token()
+	if err != nil {
+		log.Error(err, "refresh access token failed", "provider", provider)
+		m.store(key, nil)
+		var re *oauth2.RetrieveError
+		if errors.As(err, &re) {
+			return nil, errorx.NewHTTP(err, http.StatusUnauthorized, "refresh token rejected")
+		}
+		return nil, errorx.NewHTTP(err, http.StatusBadGateway, "refresh access token failed")
+	}
+	m.store(key, token)
+	log.V(1).Info("access token refreshed", "provider", provider, "expiry", token.Expiry)
+	return token, nil
+}
+
+// lock returns the lock of the token, held while it is refreshed. It is released with unlock
+func (m *TokenManager) lock(key string) *tokenLock {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.refreshing == nil {
+		m.refreshing = map[string]*tokenLock{}
+	}
+	l, ok := m.refreshing[key]
+	if !ok {
+		l = &tokenLock{}
+		m.refreshing[key] = l
+	}
+	l.users++
+	return l
+}
+
+// unlock releases the lock of the token, it is dropped when no other call waits for it
+func (m *TokenManager) unlock(key string, l *tokenLock) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l.users--
+	if l.users == 0 {
+		delete(m.refreshing, key)
+	}
+	l.Unlock()
+}
+
+// cached returns the token of the key, nil when there is none
+func (m *TokenManager) cached(key string) *oauth2.Token {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.tokens[key]
+}
+
+// store records the token of the key, it is dropped when nil
+func (m *TokenManager) store(key string, token *oauth2.Token) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if token == nil {
+		delete(m.tokens, key)
+		return
+	}
+	if m.tokens == nil {
+		m.tokens = map[string]*oauth2.Token{}
+	}
+	m.tokens[key] = token
+}
+
+// Forget drops the token of the provider for the identity, the next call refreshes it.
+// Call it when the identity is deleted or its link with the provider is revoked
+func (m *TokenManager) Forget(identityID, provider string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.tokens, identityID+"/"+provider)
+}
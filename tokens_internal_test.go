@@ -0,0 +1,67 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	client "github.com/ory/kratos-client-go"
+	"golang.org/x/oauth2"
+)
+
+func TestTokenManager_Drop(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := r.ParseForm(); err != nil || r.Form.Get("refresh_token") == "revoked" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"access_token":"access-token","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer srv.Close()
+	m := NewTokenManager(map[string]*oauth2.Config{
+		"google": {ClientID: "id", Endpoint: oauth2.Endpoint{TokenURL: srv.URL, AuthStyle: oauth2.AuthStyleInParams}},
+	})
+	ctx := SetSessionInCtx(context.Background(), &client.Session{Identity: client.Identity{Id: "identity-id"}})
+	expired := func(refresh string) *oauth2.Token {
+		return &oauth2.Token{AccessToken: "old", RefreshToken: refresh, Expiry: time.Now().Add(-time.Hour)}
+	}
+
+	m.tokens["identity-id/google"] = expired("valid")
+	if _, err := m.AccessToken(ctx, "google"); err != nil {
+		t.Fatalf("AccessToken() error = %v", err)
+	}
+	if len(m.tokens) != 1 || len(m.refreshing) != 0 {
+		t.Errorf("after a refresh %d tokens and %d locks are kept, want 1 and 0", len(m.tokens), len(m.refreshing))
+	}
+	m.Forget("identity-id", "google")
+	if len(m.tokens) != 0 {
+		t.Errorf("Forget() kept %d tokens", len(m.tokens))
+	}
+
+	m.tokens["identity-id/google"] = expired("revoked")
+	if _, err := m.AccessToken(ctx, "google"); err == nil {
+		t.Fatal("AccessToken() expected an error for the revoked refresh token")
+	}
+	if len(m.tokens) != 0 || len(m.refreshing) != 0 {
+		t.Errorf("after a rejected refresh %d tokens and %d locks are kept, want none", len(m.tokens), len(m.refreshing))
+	}
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/oauth2"
+
+	"github.com/w6d-io/kratox"
+)
+
+// fakeTokenEndpoint exchanges the refresh tokens, the access tokens expire after expiresIn seconds
+func fakeTokenEndpoint(t *testing.T, expiresIn int, calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(calls, 1)
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("parse token request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.Form.Get("grant_type") != "refresh_token" || r.Form.Get("refresh_token") == "revoked" {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":  "access-token-" + string(rune('0'+n)),
+			"token_type":    "Bearer",
+			"expires_in":    expiresIn,
+			"refresh_token": "rotated-refresh-token",
+		})
+	}))
+}
+
+func TestTokenManager(t *testing.T) {
+	ctx := kratox.SetSessionInCtx(context.Background(), session)
+	config := func(url string) map[string]*oauth2.Config {
+		return map[string]*oauth2.Config{
+			"google": {ClientID: "id", ClientSecret: "secret", Endpoint: oauth2.Endpoint{TokenURL: url, AuthStyle: oauth2.AuthStyleInParams}},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		expiresIn int
+		mock      kratosMock
+		provider  string
+		calls     int
+		wantCalls int32
+		wantToken string
+		wantErr   bool
+	}{
+		{
+			name:      "cached until expiry",
+			expiresIn: 3600,
+			mock:      kratosMock{token: true, provider: "google"},
+			provider:  "google",
+			calls:     3,
+			wantCalls: 1,
+			wantToken: "access-token-1",
+		},
+		{
+			name:      "refreshed once expired",
+			expiresIn: 1,
+			mock:      kratosMock{token: true, provider: "google"},
+			provider:  "google",
+			calls:     2,
+			wantCalls: 2,
+			wantToken: "access-token-2",
+		},
+		{
+			name:     "provider not configured",
+			mock:     kratosMock{token: true, provider: "github"},
+			provider: "github",
+			calls:    1,
+			wantErr:  true,
+		},
+		{
+			name:     "provider not linked",
+			mock:     kratosMock{token: false, provider: "google"},
+			provider: "google",
+			calls:    1,
+			wantErr:  true,
+		},
+		{
+			name:     "kratos failure",
+			mock:     kratosMock{behaviour: "ko", token: true, provider: "google"},
+			provider: "google",
+			calls:    1,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			srv := fakeTokenEndpoint(t, tt.expiresIn, &calls)
+			defer srv.Close()
+			kratox.Kratox = tt.mock
+			m := kratox.NewTokenManager(config(srv.URL))
+
+			var token *oauth2.Token
+			var err error
+			for i := 0; i < tt.calls; i++ {
+				token, err = m.AccessToken(ctx, tt.provider)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AccessToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if token.AccessToken != tt.wantToken || calls != tt.wantCalls {
+				t.Errorf("AccessToken() = %s after %d calls, want %s after %d", token.AccessToken, calls, tt.wantToken, tt.wantCalls)
+			}
+			if token.RefreshToken != "rotated-refresh-token" {
+				t.Errorf("AccessToken() refresh token = %s", token.RefreshToken)
+			}
+		})
+	}
+}
+
+func TestTokenManager_ConcurrentRefresh(t *testing.T) {
+	ctx := kratox.SetSessionInCtx(context.Background(), session)
+	var calls int32
+	srv := fakeTokenEndpoint(t, 3600, &calls)
+	defer srv.Close()
+	kratox.Kratox = kratosMock{token: true, provider: "google"}
+	m := kratox.NewTokenManager(map[string]*oauth2.Config{
+		"google": {ClientID: "id", ClientSecret: "secret", Endpoint: oauth2.Endpoint{TokenURL: srv.URL, AuthStyle: oauth2.AuthStyleInParams}},
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := m.AccessToken(ctx, "google"); err != nil {
+				t.Errorf("AccessToken() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Errorf("token endpoint called %d times, want 1", calls)
+	}
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	client "github.com/ory/kratos-client-go"
+
+	"github.com/w6d-io/x/errorx"
+	"github.com/w6d-io/x/logx"
+)
+
+var (
+	errCodeNotAccepted = errorx.New("verification code not accepted")
+)
+
+const (
+	// verificationPassed is the state of a verification flow once the code is accepted
+	verificationPassed = "passed_challenge"
+)
+
+// SendVerificationCode creates an api verification flow and sends the verification code to the email.
+// It returns the id of the flow on which the code is submitted with VerifyAddress
+// if kratos is unreachable or an other issues, return an empty id with statusCode of the call and error-go
+func (a auth) SendVerificationCode(ctx context.Context, email string) (string, error) {
+	log := logx.WithName(ctx, "SendVerificationCode")
+	var flow flowBody
+	if err := a.flowCall(ctx, http.MethodGet, "/self-service/verification/api", nil, Credential{}, nil, &flow); err != nil {
+		log.Error(err, "create verification flow failed")
+		return "", err
+	}
+	body := map[string]interface{}{
+		"method": "code",
+		"email":  email,
+	}
+	if err := a.flowCall(ctx, http.MethodPost, "/self-service/verification", url.Values{"flow": []string{flow.ID}}, Credential{}, body, &flow); err != nil {
+		return "", err
+	}
+	log.V(1).Info("verification code sent", "flow", flow.ID, "state", flow.State)
+	return flow.ID, nil
+}
+
+// VerifyAddress submits the verification code on the flow created by SendVerificationCode.
+// When the code is not accepted a *FlowError holding the messages is returned
+// if kratos is unreachable or an other issues, return statusCode of the call and error-go
+func (a auth) VerifyAddress(ctx context.Context, flowID, code string) error {
+	log := logx.WithName(ctx, "VerifyAddress")
+	body := map[string]interface{}{
+		"method": "code",
+		"code":   code,
+	}
+	var raw json.RawMessage
+	if err := a.flowCall(ctx, http.MethodPost, "/self-service/verification", url.Values{"flow": []string{flowID}}, Credential{}, body, &raw); err != nil {
+		return err
+	}
+	var flow flowBody
+	if err := json.Unmarshal(raw, &flow); err != nil {
+		log.Error(err, "decode verification flow failed")
+		return errorx.NewHTTP(err, http.StatusInternalServerError, "decode verification flow failed")
+	}
+	if flow.State != verificationPassed {
+		log.V(1).Info("verification code not accepted", "flow", flow.ID, "state", flow.State)
+		if flowErr := newFlowError(http.StatusBadRequest, raw); flowErr != nil {
+			return flowErr
+		}
+		return errorx.NewHTTP(errCodeNotAccepted, http.StatusBadRequest, "verification code not accepted")
+	}
+	log.V(1).Info("address verified", "flow", flow.ID)
+	return nil
+}
+
+// GetVerifiableAddresses returns the addresses of the identity with their verification status
+// if kratos is unreachable or an other issues, return nil with statusCode of the call and error-go
+func (a auth) GetVerifiableAddresses(ctx context.Context, identityID string) ([]client.VerifiableIdentityAddress, error) {
+	i, err := a.GetIdentity(ctx, identityID)
+	if err != nil {
+		return nil, err
+	}
+	return i.VerifiableAddresses, nil
+}
+
+// IsAddressVerified reports whether the address of the identity is verified,
+// false when the identity has no such address
+func (a auth) IsAddressVerified(ctx context.Context, identityID, address string) (bool, error) {
+	addresses, err := a.GetVerifiableAddresses(ctx, identityID)
+	if err != nil {
+		return false, err
+	}
+	for _, va := range addresses {
+		if va.Value == address {
+			return va.Verified, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/w6d-io/kratox"
+	"github.com/w6d-io/x/errorx"
+)
+
+func fakeVerification(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/self-service/verification/api", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"id": "flow-id", "state": "choose_method"})
+	})
+	mux.HandleFunc("/self-service/verification", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode verification body: %v", err)
+		}
+		switch {
+		case body["email"] == "a@b.c":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"id": "flow-id", "state": "sent_email"})
+		case body["email"] != "":
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(flowWithMessages("flow-id", "choose_method", "email", "not a valid email"))
+		case body["code"] == "111111":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"state": "sent_email"})
+		case body["code"] == "123456":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"id": "flow-id", "state": "passed_challenge"})
+		default:
+			_ = json.NewEncoder(w).Encode(flowWithMessages("flow-id", "sent_email", "code", "The verification code is invalid or has already been used."))
+		}
+	})
+	mux.HandleFunc("/admin/identities/identity-id", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"id": "identity-id", "schema_id": "default", "schema_url": "", "traits": map[string]string{},
+			"verifiable_addresses": []interface{}{
+				map[string]interface{}{"id": "1", "value": "a@b.c", "verified": true, "via": "email", "status": "completed"},
+				map[string]interface{}{"id": "2", "value": "d@e.f", "verified": false, "via": "email", "status": "pending"},
+			},
+		})
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestVerification(t *testing.T) {
+	srv := fakeVerification(t)
+	defer srv.Close()
+	kratox.SetAddress(srv.URL, srv.URL)
+	ctx := context.Background()
+
+	flowID, err := kratox.Kratox.SendVerificationCode(ctx, "a@b.c")
+	if err != nil || flowID != "flow-id" {
+		t.Fatalf("SendVerificationCode() = %s, %v", flowID, err)
+	}
+	if _, err := kratox.Kratox.SendVerificationCode(ctx, "invalid"); err == nil {
+		t.Error("SendVerificationCode() expected an error for an invalid email")
+	}
+
+	tests := []struct {
+		name        string
+		code        string
+		wantErr     bool
+		wantFlowErr bool
+	}{
+		{name: "valid code", code: "123456"},
+		{name: "invalid code", code: "000000", wantErr: true, wantFlowErr: true},
+		{name: "flow without id", code: "111111", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := kratox.Kratox.VerifyAddress(ctx, flowID, tt.code)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyAddress() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var fe *kratox.FlowError
+			if tt.wantFlowErr && (!errors.As(err, &fe) || len(fe.Fields["code"]) == 0) {
+				t.Errorf("VerifyAddress() error = %v, want a FlowError on code", err)
+			}
+			var e *errorx.Error
+			if tt.wantErr && !tt.wantFlowErr && (!errors.As(err, &e) || e.StatusCode != http.StatusBadRequest) {
+				t.Errorf("VerifyAddress() error = %v, want the bad request status", err)
+			}
+		})
+	}
+
+	for address, want := range map[string]bool{"a@b.c": true, "d@e.f": false, "unknown": false} {
+		got, err := kratox.Kratox.IsAddressVerified(ctx, "identity-id", address)
+		if err != nil || got != want {
+			t.Errorf("IsAddressVerified(%s) = %v, %v, want %v", address, got, err, want)
+		}
+	}
+}
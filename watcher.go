@@ -0,0 +1,357 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	client "github.com/ory/kratos-client-go"
+
+	"github.com/w6d-io/x/errorx"
+	"github.com/w6d-io/x/logx"
+)
+
+const (
+	// defaultWatchInterval is the pause between two listings of the watcher
+	defaultWatchInterval = time.Minute
+)
+
+// ChangeType is the kind of change of an identity
+type ChangeType string
+
+const (
+	// ChangeCreated is emitted for the identities missing from the checkpoint
+	ChangeCreated ChangeType = "created"
+	// ChangeUpdated is emitted for the identities updated since the checkpoint
+	ChangeUpdated ChangeType = "updated"
+	// ChangeDeleted is emitted for the identities of the checkpoint not listed anymore,
+	// once kratos confirms they are not found
+	ChangeDeleted ChangeType = "deleted"
+)
+
+// IdentityChange is a change of an identity found by the watcher
+type IdentityChange struct {
+	// Type of the change
+	Type ChangeType
+	// ID of the identity
+	ID string
+	// Identity as listed, nil when deleted
+	Identity *client.Identity
+}
+
+// Checkpoint holds the last update time of the identities seen by the watcher, by id
+type Checkpoint map[string]time.Time
+
+// CheckpointStore persists the checkpoint between the runs of the watcher
+type CheckpointStore interface {
+	// Load returns the last saved checkpoint, an empty one when there is none
+	Load(context.Context) (Checkpoint, error)
+
+	// Save records the checkpoint
+	Save(context.Context, Checkpoint) error
+}
+
+// MemoryCheckpoint keeps the checkpoint in memory, the watcher emits all the identities as created
+// when the process starts
+type MemoryCheckpoint struct {
+	mu sync.Mutex
+	cp Checkpoint
+}
+
+var _ CheckpointStore = &MemoryCheckpoint{}
+
+// Load implements CheckpointStore
+func (m *MemoryCheckpoint) Load(_ context.Context) (Checkpoint, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return copyCheckpoint(m.cp), nil
+}
+
+// Save implements CheckpointStore
+func (m *MemoryCheckpoint) Save(_ context.Context, cp Checkpoint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cp = copyCheckpoint(cp)
+	return nil
+}
+
+// FileCheckpoint keeps the checkpoint in a json file, replaced atomically on save
+type FileCheckpoint struct {
+	// Path of the file
+	Path string `json:"path" mapstructure:"path"`
+}
+
+var _ CheckpointStore = &FileCheckpoint{}
+
+// Load implements CheckpointStore, the checkpoint is empty when the file does not exist
+func (f *FileCheckpoint) Load(ctx context.Context) (Checkpoint, error) {
+	log := logx.WithName(ctx, "FileCheckpoint")
+	data, err := os.ReadFile(f.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Checkpoint{}, nil
+	}
+	if err != nil {
+		log.Error(err, "read checkpoint failed", "path", f.Path)
+		return nil, errorx.Wrap(err, "read checkpoint failed")
+	}
+	cp := Checkpoint{}
+	if err := json.Unmarshal(data, &cp); err != nil {
+		log.Error(err, "decode checkpoint failed", "path", f.Path)
+		return nil, errorx.Wrap(err, "decode checkpoint failed")
+	}
+	return cp, nil
+}
+
+// Save implements CheckpointStore
+func (f *FileCheckpoint) Save(ctx context.Context, cp Checkpoint) error {
+	log := logx.WithName(ctx, "FileCheckpoint")
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return errorx.Wrap(err, "encode checkpoint failed")
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.Path), filepath.Base(f.Path)+".*")
+	if err != nil {
+		log.Error(err, "write checkpoint failed", "path", f.Path)
+		return errorx.Wrap(err, "write checkpoint failed")
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		log.Error(err, "write checkpoint failed", "path", f.Path)
+		return errorx.Wrap(err, "write checkpoint failed")
+	}
+	if err := tmp.Close(); err != nil {
+		log.Error(err, "write checkpoint failed", "path", f.Path)
+		return errorx.Wrap(err, "write checkpoint failed")
+	}
+	if err := os.Rename(tmp.Name(), f.Path); err != nil {
+		log.Error(err, "write checkpoint failed", "path", f.Path)
+		return errorx.Wrap(err, "write checkpoint failed")
+	}
+	return nil
+}
+
+// Watcher lists the identities periodically and emits their changes since the checkpoint,
+// kratos has no change feed. The changes are delivered at least once: when a change fails, the
+// checkpoint is saved with the changes delivered before it, they are not emitted again, and the
+// failing change and the next ones are emitted again at the next poll
+type Watcher struct {
+	// Store persists the checkpoint, a MemoryCheckpoint when nil
+	Store CheckpointStore
+	// Interval between two listings, a minute when zero
+	Interval time.Duration
+	// Handler is called with each change, when set
+	Handler func(context.Context, IdentityChange) error
+	// Events receives each change, when set
+	Events chan<- IdentityChange
+	// Kratos lists the identities, Kratox when nil
+	Kratos Helper
+
+	mu sync.Mutex
+}
+
+// NewWatcher returns the watcher saving its checkpoint into the store, a MemoryCheckpoint when nil
+func NewWatcher(store CheckpointStore, interval time.Duration) *Watcher {
+	if store == nil {
+		store = &MemoryCheckpoint{}
+	}
+	return &Watcher{
+		Store:    store,
+		Interval: interval,
+	}
+}
+
+// store returns the checkpoint store, it sets the MemoryCheckpoint of the watchers built without store
+func (w *Watcher) store() CheckpointStore {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.Store == nil {
+		w.Store = &MemoryCheckpoint{}
+	}
+	return w.Store
+}
+
+// Run polls kratos until the context is done. The failed polls are logged and run again
+// at the next interval
+func (w *Watcher) Run(ctx context.Context) error {
+	log := logx.WithName(ctx, "Watcher")
+	interval := w.Interval
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := w.Poll(ctx); err != nil && ctx.Err() == nil {
+			log.Error(err, "poll identities failed")
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll lists the identities once, delivers their changes since the checkpoint and saves it.
+// An identity missing from the listing is only emitted as deleted once kratos does not find it.
+// It returns the delivered changes
+func (w *Watcher) Poll(ctx context.Context) ([]IdentityChange, error) {
+	log := logx.WithName(ctx, "Watcher")
+	store := w.store()
+	kratos := w.Kratos
+	if kratos == nil {
+		kratos = Kratox
+	}
+	cp, err := store.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if cp == nil {
+		cp = Checkpoint{}
+	}
+	identities, err := kratos.ListAllIdentities(ctx)
+	if err != nil {
+		return nil, err
+	}
+	changes := diffIdentities(cp, identities)
+	log.V(1).Info("identities polled", "count", len(identities), "changes", len(changes))
+
+	var delivered []IdentityChange
+	// fail saves the checkpoint of the changes delivered before the failure
+	fail := func(err error) ([]IdentityChange, error) {
+		if serr := store.Save(ctx, cp); serr != nil {
+			log.Error(serr, "save checkpoint failed")
+		}
+		return delivered, err
+	}
+	for _, c := range changes {
+		if c.Type == ChangeDeleted {
+			gone, err := notFound(ctx, kratos, c.ID)
+			if err != nil {
+				log.Error(err, "confirm identity deletion failed", "id", c.ID)
+				return fail(err)
+			}
+			if !gone {
+				log.V(1).Info("identity missing from the listing still exists", "id", c.ID)
+				continue
+			}
+		}
+		if err := w.deliver(ctx, c); err != nil {
+			log.Error(err, "deliver identity change failed", "id", c.ID, "type", c.Type)
+			return fail(err)
+		}
+		if c.Type == ChangeDeleted {
+			delete(cp, c.ID)
+		} else {
+			cp[c.ID] = updatedAt(c.Identity)
+		}
+		delivered = append(delivered, c)
+	}
+	if len(delivered) == 0 {
+		return nil, nil
+	}
+	return delivered, store.Save(ctx, cp)
+}
+
+// notFound reports whether kratos does not find the identity
+func notFound(ctx context.Context, kratos Helper, id string) (bool, error) {
+	_, err := kratos.GetIdentity(ctx, id)
+	if err == nil {
+		return false, nil
+	}
+	var e *errorx.Error
+	if errors.As(err, &e) && e.StatusCode == http.StatusNotFound {
+		return true, nil
+	}
+	return false, err
+}
+
+// deliver sends the change to the handler and the channel
+func (w *Watcher) deliver(ctx context.Context, c IdentityChange) error {
+	if w.Handler != nil {
+		if err := w.Handler(ctx, c); err != nil {
+			return err
+		}
+	}
+	if w.Events != nil {
+		select {
+		case w.Events <- c:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// diffIdentities returns the changes of the identities since the checkpoint, the created and updated
+// ones by update time then the deleted ones
+func diffIdentities(cp Checkpoint, identities []client.Identity) []IdentityChange {
+	var changes []IdentityChange
+	listed := make(map[string]bool, len(identities))
+	for i := range identities {
+		identity := &identities[i]
+		listed[identity.Id] = true
+		last, ok := cp[identity.Id]
+		switch {
+		case !ok:
+			changes = append(changes, IdentityChange{Type: ChangeCreated, ID: identity.Id, Identity: identity})
+		case updatedAt(identity).After(last):
+			changes = append(changes, IdentityChange{Type: ChangeUpdated, ID: identity.Id, Identity: identity})
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return updatedAt(changes[i].Identity).Before(updatedAt(changes[j].Identity))
+	})
+	var deleted []string
+	for id := range cp {
+		if !listed[id] {
+			deleted = append(deleted, id)
+		}
+	}
+	sort.Strings(deleted)
+	for _, id := range deleted {
+		changes = append(changes, IdentityChange{Type: ChangeDeleted, ID: id})
+	}
+	return changes
+}
+
+// updatedAt returns the update time of the identity, its creation time when kratos does not report it
+func updatedAt(identity *client.Identity) time.Time {
+	if identity.UpdatedAt != nil {
+		return *identity.UpdatedAt
+	}
+	if identity.CreatedAt != nil {
+		return *identity.CreatedAt
+	}
+	return time.Time{}
+}
+
+func copyCheckpoint(cp Checkpoint) Checkpoint {
+	out := make(Checkpoint, len(cp))
+	for id, t := range cp {
+		out[id] = t
+	}
+	return out
+}
@@ -0,0 +1,276 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/w6d-io/kratox"
+)
+
+// fakeIdentities serves the identities two by page, in id order, with the keyset pagination or,
+// as kratos before v1.1, with the page numbers. The hidden identities exist but are missing from the listing
+type fakeIdentities struct {
+	mu      sync.Mutex
+	updated map[string]time.Time
+	hidden  map[string]bool
+	numbers bool
+}
+
+func (f *fakeIdentities) set(id string, t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.updated[id] = t
+}
+
+func (f *fakeIdentities) remove(id string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.updated, id)
+}
+
+func (f *fakeIdentities) hide(id string, hidden bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.hidden == nil {
+		f.hidden = map[string]bool{}
+	}
+	f.hidden[id] = hidden
+}
+
+func (f *fakeIdentities) identity(id string) map[string]interface{} {
+	return map[string]interface{}{
+		"id": id, "schema_id": "default", "schema_url": "", "traits": map[string]string{"email": id + "@b.c"},
+		"created_at": f.updated[id].Add(-time.Hour), "updated_at": f.updated[id],
+	}
+}
+
+func (f *fakeIdentities) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	if id := strings.TrimPrefix(r.URL.Path, "/admin/identities/"); id != r.URL.Path {
+		if _, ok := f.updated[id]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]interface{}{"code": 404, "message": "not found"}})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(f.identity(id))
+		return
+	}
+	if r.URL.Path != "/admin/identities" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	// the page token is the last id of the previous page
+	after := r.URL.Query().Get("page_token")
+	if f.numbers {
+		after = ""
+	}
+	var ids []string
+	for id := range f.updated {
+		if id > after && !f.hidden[id] {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	if f.numbers {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		start, end := page*2, page*2+2
+		if start > len(ids) {
+			start = len(ids)
+		}
+		if end < len(ids) {
+			w.Header().Set("Link", `</admin/identities?page=0&per_page=2>; rel="first",</admin/identities?page=`+strconv.Itoa(page+1)+`&per_page=2>; rel="next"`)
+		} else {
+			end = len(ids)
+		}
+		ids = ids[start:end]
+	} else if len(ids) > 2 {
+		ids = ids[:2]
+		w.Header().Set("Link", `</admin/identities?page_size=2>; rel="first",</admin/identities?page_size=2&page_token=`+ids[1]+`>; rel="next"`)
+	}
+	identities := []map[string]interface{}{}
+	for _, id := range ids {
+		identities = append(identities, f.identity(id))
+	}
+	_ = json.NewEncoder(w).Encode(identities)
+}
+
+func TestWatcher_Poll(t *testing.T) {
+	now := time.Now().UTC()
+	fake := &fakeIdentities{updated: map[string]time.Time{
+		"a": now.Add(-time.Minute),
+		"b": now.Add(-3 * time.Minute),
+		"c": now.Add(-2 * time.Minute),
+	}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	kratox.SetAddress(srv.URL, srv.URL)
+	ctx := context.Background()
+
+	identities, err := kratox.Kratox.ListAllIdentities(ctx)
+	if err != nil || len(identities) != 3 {
+		t.Fatalf("ListAllIdentities() = %d identities, %v", len(identities), err)
+	}
+
+	store := &kratox.FileCheckpoint{Path: filepath.Join(t.TempDir(), "checkpoint.json")}
+	w := kratox.NewWatcher(store, time.Minute)
+	steps := []struct {
+		name   string
+		change func()
+		want   []string
+	}{
+		{name: "initial sync", change: func() {}, want: []string{"created b", "created c", "created a"}},
+		{name: "no change", change: func() {}},
+		{name: "changes", change: func() {
+			fake.set("b", now)
+			fake.set("d", now.Add(-time.Second))
+			fake.remove("c")
+		}, want: []string{"created d", "updated b", "deleted c"}},
+		{name: "missing from the listing", change: func() {
+			fake.hide("a", true)
+		}},
+		{name: "listed again", change: func() {
+			fake.hide("a", false)
+		}},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			step.change()
+			changes, err := kratox.NewWatcher(store, time.Minute).Poll(ctx)
+			if err != nil {
+				t.Fatalf("Poll() error = %v", err)
+			}
+			var got []string
+			for _, c := range changes {
+				got = append(got, string(c.Type)+" "+c.ID)
+			}
+			if len(got) != len(step.want) {
+				t.Fatalf("Poll() = %v, want %v", got, step.want)
+			}
+			for i := range got {
+				if got[i] != step.want[i] {
+					t.Errorf("Poll() = %v, want %v", got, step.want)
+				}
+			}
+		})
+	}
+
+	t.Run("handler failure", func(t *testing.T) {
+		fake.set("a", now.Add(time.Second))
+		fake.set("e", now.Add(2*time.Second))
+		failed := false
+		w.Handler = func(_ context.Context, c kratox.IdentityChange) error {
+			if c.ID == "e" && !failed {
+				failed = true
+				return errors.New("database down")
+			}
+			return nil
+		}
+		changes, err := w.Poll(ctx)
+		if err == nil || len(changes) != 1 || changes[0].ID != "a" {
+			t.Fatalf("Poll() = %v, %v", changes, err)
+		}
+		// the change of a was delivered before the failure, only the failed one is emitted again
+		changes, err = w.Poll(ctx)
+		if err != nil || len(changes) != 1 || changes[0].ID != "e" {
+			t.Errorf("Poll() after the failure = %v, %v, want only the failed change", changes, err)
+		}
+	})
+}
+
+func TestWatcher_PageNumbers(t *testing.T) {
+	now := time.Now().UTC()
+	fake := &fakeIdentities{numbers: true, updated: map[string]time.Time{}}
+	for i, id := range []string{"a", "b", "c", "d", "e"} {
+		fake.updated[id] = now.Add(time.Duration(i) * time.Second)
+	}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	kratox.SetAddress(srv.URL, srv.URL)
+	ctx := context.Background()
+
+	w := kratox.NewWatcher(nil, time.Minute)
+	changes, err := w.Poll(ctx)
+	if err != nil || len(changes) != 5 {
+		t.Fatalf("Poll() = %v, %v, want the 5 identities of the 3 pages", changes, err)
+	}
+	fake.set("e", now.Add(time.Minute))
+	changes, err = w.Poll(ctx)
+	if err != nil || len(changes) != 1 || changes[0].Type != kratox.ChangeUpdated || changes[0].ID != "e" {
+		t.Errorf("Poll() = %v, %v, want e of the last page updated", changes, err)
+	}
+}
+
+func TestWatcher_Run(t *testing.T) {
+	fake := &fakeIdentities{updated: map[string]time.Time{"a": time.Now()}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	events := make(chan kratox.IdentityChange, 1)
+	w := &kratox.Watcher{Interval: 10 * time.Millisecond, Events: events}
+	kratox.SetAddress(srv.URL, srv.URL)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- w.Run(ctx) }()
+
+	if c := <-events; c.Type != kratox.ChangeCreated || c.ID != "a" || c.Identity == nil {
+		t.Errorf("Run() emitted %+v", c)
+	}
+	fake.remove("a")
+	if c := <-events; c.Type != kratox.ChangeDeleted || c.ID != "a" {
+		t.Errorf("Run() emitted %+v", c)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() error = %v", err)
+	}
+}
+
+func TestNewWatcher(t *testing.T) {
+	if w := kratox.NewWatcher(nil, time.Minute); w.Store == nil {
+		t.Error("NewWatcher() without store has no checkpoint store")
+	}
+}
+
+func TestFileCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	store := &kratox.FileCheckpoint{Path: filepath.Join(t.TempDir(), "checkpoint.json")}
+	cp, err := store.Load(ctx)
+	if err != nil || len(cp) != 0 {
+		t.Fatalf("Load() without file = %v, %v", cp, err)
+	}
+	at := time.Date(2026, 10, 19, 10, 0, 0, 123456789, time.UTC)
+	if err := store.Save(ctx, kratox.Checkpoint{"a": at}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	cp, err = store.Load(ctx)
+	if err != nil || !cp["a"].Equal(at) {
+		t.Errorf("Load() = %v, %v", cp, err)
+	}
+}
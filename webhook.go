@@ -0,0 +1,298 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	client "github.com/ory/kratos-client-go"
+
+	"github.com/w6d-io/x/errorx"
+	"github.com/w6d-io/x/logx"
+)
+
+// WebhookEvent is the type of the event posted by a kratos web_hook action
+type WebhookEvent string
+
+const (
+	// IdentityCreated is posted by the after registration hooks
+	IdentityCreated WebhookEvent = "identity.created"
+	// IdentityUpdated is posted by the after settings hooks
+	IdentityUpdated WebhookEvent = "identity.updated"
+	// LoginSucceeded is posted by the after login hooks
+	LoginSucceeded WebhookEvent = "login"
+)
+
+const (
+	// maxWebhookBody is the size limit of the payloads posted by kratos
+	maxWebhookBody = 1 << 20
+)
+
+var (
+	errWebhookUnauthorized = errorx.New("webhook credential mismatch")
+	errNoWebhookEvent      = errorx.New("webhook event type not found")
+)
+
+// Event is the payload posted by kratos. The web_hook body jsonnet gives the event type and
+// forwards the context, ex for the after registration hook:
+//
+//	function(ctx) { event: "identity.created", flow: ctx.flow, identity: ctx.identity }
+//
+// The type is read from the event query parameter of the hook url when the payload does not hold it
+type Event struct {
+	// Type of the event
+	Type WebhookEvent `json:"event"`
+	// Flow is the self-service flow running the hook
+	Flow EventFlow `json:"flow"`
+	// Identity is the identity of the flow
+	Identity *client.Identity `json:"identity,omitempty"`
+	// Session is the session issued by the flow, when forwarded
+	Session *client.Session `json:"session,omitempty"`
+	// RequestHeaders are the headers of the request which ran the flow, when forwarded
+	RequestHeaders map[string][]string `json:"request_headers,omitempty"`
+	// RequestURL is the url of the request which ran the flow, when forwarded
+	RequestURL string `json:"request_url,omitempty"`
+	// RequestMethod is the method of the request which ran the flow, when forwarded
+	RequestMethod string `json:"request_method,omitempty"`
+	// Raw is the payload as posted, for the fields added by the jsonnet
+	Raw json.RawMessage `json:"-"`
+}
+
+// EventFlow is the part of the flow forwarded to the hooks
+type EventFlow struct {
+	// ID of the flow
+	ID string `json:"id"`
+	// Type is api or browser
+	Type string `json:"type"`
+	// RequestURL is the url the flow was initialized with
+	RequestURL string `json:"request_url,omitempty"`
+}
+
+// EventHandler is a callback of the webhook, a *WebhookInterrupt error is returned to kratos
+// to block the flow when the hook is configured with response.parse
+type EventHandler func(context.Context, *Event) error
+
+// WebhookAuth verifies the credential kratos sends with the web_hook auth config, an api_key
+// in the header or basic_auth. The requests are rejected when neither is set, unless
+// AllowUnauthenticated is set
+type WebhookAuth struct {
+	// Secret is the value of the api_key
+	Secret string `json:"secret" mapstructure:"secret"`
+	// Header is the name of the api_key header, Authorization when empty
+	Header string `json:"header" mapstructure:"header"`
+	// Username of the basic_auth
+	Username string `json:"username" mapstructure:"username"`
+	// Password of the basic_auth
+	Password string `json:"password" mapstructure:"password"`
+	// AllowUnauthenticated accepts the requests without credential when neither the api_key nor
+	// the basic_auth is set, for a hook only reachable by kratos
+	AllowUnauthenticated bool `json:"allowUnauthenticated" mapstructure:"allowUnauthenticated"`
+}
+
+// Verify reports whether the request holds the configured credential, it is false when no
+// credential is configured and the unauthenticated requests are not allowed
+func (a WebhookAuth) Verify(r *http.Request) bool {
+	if a.Secret == "" && a.Username == "" && a.Password == "" {
+		return a.AllowUnauthenticated
+	}
+	if a.Secret != "" {
+		header := a.Header
+		if header == "" {
+			header = "Authorization"
+		}
+		if !equal(r.Header.Get(header), a.Secret) {
+			return false
+		}
+	}
+	if a.Username != "" || a.Password != "" {
+		username, password, ok := r.BasicAuth()
+		if !ok || !equal(username, a.Username) || !equal(password, a.Password) {
+			return false
+		}
+	}
+	return true
+}
+
+// equal compares the values in constant time
+func equal(got, want string) bool {
+	return subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
+
+// Webhook is an http.Handler receiving the kratos web_hook actions and dispatching the events
+// to the handlers registered for their type. The events without handler are acknowledged
+type Webhook struct {
+	WebhookAuth
+
+	mu       sync.RWMutex
+	handlers map[WebhookEvent][]EventHandler
+}
+
+var _ http.Handler = &Webhook{}
+
+// NewWebhook returns the webhook verifying the credential
+func NewWebhook(auth WebhookAuth) *Webhook {
+	return &Webhook{
+		WebhookAuth: auth,
+		handlers:    map[WebhookEvent][]EventHandler{},
+	}
+}
+
+// On registers the handler for the event type, the handlers are called in the order they are registered
+func (wh *Webhook) On(event WebhookEvent, h EventHandler) *Webhook {
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+	if wh.handlers == nil {
+		wh.handlers = map[WebhookEvent][]EventHandler{}
+	}
+	wh.handlers[event] = append(wh.handlers[event], h)
+	return wh
+}
+
+// ServeHTTP verifies and decodes the payload then calls the handlers of the event.
+// The first handler error stops the dispatch, an interrupt is written as kratos expects it
+// and the other errors respond with their status code
+func (wh *Webhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	log := logx.WithName(r.Context(), "Webhook")
+	e, err := readEvent(w, r, wh.WebhookAuth)
+	if err == nil && e.Type == "" {
+		log.Error(errNoWebhookEvent, "decode webhook payload failed")
+		err = errorx.NewHTTP(errNoWebhookEvent, http.StatusBadRequest, "event type not found")
+	}
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	wh.mu.RLock()
+	handlers := wh.handlers[e.Type]
+	wh.mu.RUnlock()
+	log.V(1).Info("webhook received", "event", e.Type, "flow", e.Flow.ID, "handlers", len(handlers))
+	for _, h := range handlers {
+		if err := h(r.Context(), e); err != nil {
+			log.Error(err, "webhook handler failed", "event", e.Type, "flow", e.Flow.ID)
+			writeWebhookError(w, err)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// readEvent verifies the request and decodes its payload
+func readEvent(w http.ResponseWriter, r *http.Request, auth WebhookAuth) (*Event, error) {
+	log := logx.WithName(r.Context(), "readEvent")
+	if r.Method != http.MethodPost {
+		return nil, errorx.NewHTTP(fmt.Errorf("method %s not allowed", r.Method), http.StatusMethodNotAllowed, "method not allowed")
+	}
+	if !auth.Verify(r) {
+		log.Error(errWebhookUnauthorized, "verify webhook failed")
+		return nil, errorx.NewHTTP(errWebhookUnauthorized, http.StatusUnauthorized, "unauthorized")
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		log.Error(err, "read webhook payload failed")
+		return nil, errorx.NewHTTP(err, http.StatusBadRequest, "read payload failed")
+	}
+	e := &Event{}
+	if err := json.Unmarshal(data, e); err != nil {
+		log.Error(err, "decode webhook payload failed")
+		return nil, errorx.NewHTTP(err, http.StatusBadRequest, "decode payload failed")
+	}
+	e.Raw = data
+	if e.Type == "" {
+		e.Type = WebhookEvent(r.URL.Query().Get("event"))
+	}
+	return e, nil
+}
+
+// writeWebhookError writes the interrupt as kratos expects it, the status code of the other errors
+func writeWebhookError(w http.ResponseWriter, err error) {
+	var interrupt *WebhookInterrupt
+	if errors.As(err, &interrupt) {
+		WriteInterrupt(w, interrupt)
+		return
+	}
+	status := http.StatusInternalServerError
+	var e *errorx.Error
+	if errors.As(err, &e) && e.StatusCode != 0 {
+		status = e.StatusCode
+	}
+	http.Error(w, http.StatusText(status), status)
+}
+
+// WebhookInterrupt is the error returned to kratos to block the flow, its messages are shown
+// on the form. Kratos reads them only when the web_hook is configured with response.parse
+type WebhookInterrupt struct {
+	// Messages by field
+	Messages []FieldMessages `json:"messages"`
+}
+
+// FieldMessages are the messages of a form field
+type FieldMessages struct {
+	// InstancePtr is the json pointer of the field into the identity (ex: #/traits/email),
+	// #/ for the messages not attached to a field
+	InstancePtr string `json:"instance_ptr"`
+	// Messages of the field
+	Messages []Message `json:"messages"`
+}
+
+// NewInterrupt returns an empty interrupt, the messages are added with Field and Trait
+func NewInterrupt() *WebhookInterrupt {
+	return &WebhookInterrupt{}
+}
+
+// Field adds the error message on the field at the json pointer
+func (i *WebhookInterrupt) Field(ptr string, id int64, text string) *WebhookInterrupt {
+	m := Message{ID: id, Type: "error", Text: text}
+	for n := range i.Messages {
+		if i.Messages[n].InstancePtr == ptr {
+			i.Messages[n].Messages = append(i.Messages[n].Messages, m)
+			return i
+		}
+	}
+	i.Messages = append(i.Messages, FieldMessages{InstancePtr: ptr, Messages: []Message{m}})
+	return i
+}
+
+// Trait adds the error message on the trait, the name is a path into the traits (ex: name/first)
+func (i *WebhookInterrupt) Trait(name string, id int64, text string) *WebhookInterrupt {
+	return i.Field("#/traits/"+strings.TrimPrefix(name, "/"), id, text)
+}
+
+// Error implements error
+func (i *WebhookInterrupt) Error() string {
+	var texts []string
+	for _, f := range i.Messages {
+		for _, m := range f.Messages {
+			texts = append(texts, f.InstancePtr+": "+m.Text)
+		}
+	}
+	return "flow interrupted: " + strings.Join(texts, ", ")
+}
+
+// WriteInterrupt writes the interrupt with the bad request status, kratos fails the flow
+// with the messages
+func WriteInterrupt(w http.ResponseWriter, i *WebhookInterrupt) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(i)
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2020 WILDCARD SA.
+
+Licensed under the WILDCARD SA License, Version 1.0 (the "License");
+WILDCARD SA is register in french corporation.
+You may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.w6d.io/licenses/LICENSE-1.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is prohibited.
+Created on 19/10/2026
+*/
+
+package kratox_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/w6d-io/kratox"
+)
+
+const identityPayload = `{"id":"identity-id","schema_id":"default","schema_url":"","traits":{"email":"a@b.c"}}`
+
+func TestWebhook(t *testing.T) {
+	var got []*kratox.Event
+	wh := kratox.NewWebhook(kratox.WebhookAuth{Secret: "secret"}).
+		On(kratox.IdentityCreated, func(ctx context.Context, e *kratox.Event) error {
+			got = append(got, e)
+			return nil
+		}).
+		On(kratox.IdentityUpdated, func(ctx context.Context, e *kratox.Event) error {
+			return kratox.NewInterrupt().Trait("email", 4000001, "email is taken").Field("#/", 4000002, "try again")
+		}).
+		On(kratox.LoginSucceeded, func(ctx context.Context, e *kratox.Event) error {
+			return errors.New("database down")
+		})
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		secret     string
+		body       string
+		wantStatus int
+		wantEvents int
+	}{
+		{name: "identity created", target: "/", secret: "secret", body: `{"event":"identity.created","flow":{"id":"flow-id","type":"api"},"identity":` + identityPayload + `}`, wantStatus: http.StatusOK, wantEvents: 1},
+		{name: "event from the query", target: "/?event=identity.created", secret: "secret", body: `{"flow":{"id":"flow-id"},"identity":` + identityPayload + `}`, wantStatus: http.StatusOK, wantEvents: 1},
+		{name: "no handler", target: "/?event=other", secret: "secret", body: `{}`, wantStatus: http.StatusOK},
+		{name: "interrupt", target: "/?event=identity.updated", secret: "secret", body: `{}`, wantStatus: http.StatusBadRequest},
+		{name: "handler failure", target: "/?event=login", secret: "secret", body: `{}`, wantStatus: http.StatusInternalServerError},
+		{name: "wrong secret", target: "/?event=identity.created", secret: "other", body: `{}`, wantStatus: http.StatusUnauthorized},
+		{name: "no event", target: "/", secret: "secret", body: `{}`, wantStatus: http.StatusBadRequest},
+		{name: "not json", target: "/?event=identity.created", secret: "secret", body: `nope`, wantStatus: http.StatusBadRequest},
+		{name: "not a post", method: http.MethodGet, target: "/?event=identity.created", secret: "secret", wantStatus: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+			method := tt.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			req := httptest.NewRequest(method, tt.target, strings.NewReader(tt.body))
+			req.Header.Set("Authorization", tt.secret)
+			w := httptest.NewRecorder()
+			wh.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("ServeHTTP() status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if len(got) != tt.wantEvents {
+				t.Fatalf("ServeHTTP() dispatched %d events, want %d", len(got), tt.wantEvents)
+			}
+			if tt.wantEvents > 0 && (got[0].Flow.ID != "flow-id" || got[0].Identity.Id != "identity-id" || len(got[0].Raw) == 0) {
+				t.Errorf("ServeHTTP() event = %+v", got[0])
+			}
+		})
+	}
+
+	t.Run("interrupt body", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/?event=identity.updated", strings.NewReader(`{}`))
+		req.Header.Set("Authorization", "secret")
+		w := httptest.NewRecorder()
+		wh.ServeHTTP(w, req)
+		var body struct {
+			Messages []struct {
+				InstancePtr string `json:"instance_ptr"`
+				Messages    []struct {
+					ID   int64  `json:"id"`
+					Text string `json:"text"`
+					Type string `json:"type"`
+				} `json:"messages"`
+			} `json:"messages"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatalf("decode interrupt: %v", err)
+		}
+		if len(body.Messages) != 2 || body.Messages[0].InstancePtr != "#/traits/email" || body.Messages[0].Messages[0].ID != 4000001 ||
+			body.Messages[0].Messages[0].Type != "error" || body.Messages[1].InstancePtr != "#/" {
+			t.Errorf("interrupt = %+v", body)
+		}
+	})
+}
+
+func TestWebhook_Unconfigured(t *testing.T) {
+	tests := []struct {
+		name       string
+		auth       kratox.WebhookAuth
+		wantStatus int
+	}{
+		{name: "no credential configured", wantStatus: http.StatusUnauthorized},
+		{name: "unauthenticated allowed", auth: kratox.WebhookAuth{AllowUnauthenticated: true}, wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wh := kratox.NewWebhook(tt.auth)
+			req := httptest.NewRequest(http.MethodPost, "/?event=identity.created", strings.NewReader(`{}`))
+			w := httptest.NewRecorder()
+			wh.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Errorf("ServeHTTP() status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestWebhookAuth_Verify(t *testing.T) {
+	tests := []struct {
+		name     string
+		auth     kratox.WebhookAuth
+		header   string
+		value    string
+		user     string
+		password string
+		want     bool
+	}{
+		{name: "no auth"},
+		{name: "unauthenticated allowed", auth: kratox.WebhookAuth{AllowUnauthenticated: true}, want: true},
+		{name: "api key", auth: kratox.WebhookAuth{Secret: "s", Header: "X-Webhook-Key"}, header: "X-Webhook-Key", value: "s", want: true},
+		{name: "api key mismatch", auth: kratox.WebhookAuth{Secret: "s", Header: "X-Webhook-Key"}, header: "Authorization", value: "s"},
+		{name: "basic auth", auth: kratox.WebhookAuth{Username: "kratos", Password: "p"}, user: "kratos", password: "p", want: true},
+		{name: "basic auth mismatch", auth: kratox.WebhookAuth{Username: "kratos", Password: "p"}, user: "kratos", password: "x"},
+		{name: "basic auth missing", auth: kratox.WebhookAuth{Username: "kratos", Password: "p"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			if tt.user != "" {
+				req.SetBasicAuth(tt.user, tt.password)
+			}
+			if got := tt.auth.Verify(req); got != tt.want {
+				t.Errorf("Verify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}